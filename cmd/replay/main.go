@@ -0,0 +1,48 @@
+// Command replay re-attempts delivery of every notification in the dead
+// letter store configured by CONFIG_FILE.
+package main
+
+import (
+	"flag"
+	"os"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/config"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/deadletter"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/notify"
+	log "github.com/sirupsen/logrus"
+)
+
+func main() {
+	url := flag.String("url", "", "dead letter store to replay, overriding dead_letter.url from CONFIG_FILE")
+	flag.Parse()
+
+	cfg, err := config.FromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var dlCfg deadletter.Config
+	if cfg.DeadLetter != nil {
+		dlCfg = *cfg.DeadLetter
+	}
+	if *url != "" {
+		dlCfg.URL = *url
+	}
+
+	store, err := deadletter.New(dlCfg)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	delivered, failed, err := deadletter.Replay(store, notify.NewSet(cfg.Destinations, cfg.Accounts))
+	log.WithFields(log.Fields{
+		"delivered": delivered,
+		"failed":    failed,
+	}).Info("Replayed dead letters")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if failed > 0 {
+		os.Exit(1)
+	}
+}
@@ -7,5 +7,5 @@ require (
 	github.com/aws/aws-sdk-go v1.38.55
 	github.com/sirupsen/logrus v1.8.1
 	golang.org/x/sys v0.11.0 // indirect
-	golang.org/x/text v0.3.8
+	golang.org/x/text v0.3.8 // indirect
 )
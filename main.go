@@ -5,24 +5,39 @@ import (
 	"compress/gzip"
 	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
-	"time"
-
+	"sync"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/archive"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/checkpoint"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/classify"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/config"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/deadletter"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/dedup"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/detect"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
 	"github.com/Techcadia/cloudtrail-console-actions/pkg/handler"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/notify"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/ocsf"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/override"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/principal"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/route"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/suppress"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/watchlist"
 	"github.com/aws/aws-lambda-go/lambda"
 	"github.com/aws/aws-sdk-go/aws"
 	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/aws/session"
 	"github.com/aws/aws-sdk-go/service/s3"
 	log "github.com/sirupsen/logrus"
-	"golang.org/x/text/cases"
-	"golang.org/x/text/language"
 )
 
 type CloudTrailFile struct {
@@ -38,10 +53,6 @@ func main() {
 	lambda.Start(Handler)
 }
 
-func Title(s string) string {
-	return cases.Title(language.Und, cases.NoLower).String(s)
-}
-
 func Handler(ctx context.Context, event handler.Event) error {
 	log.Infof("S3 event: %v", event)
 
@@ -56,7 +67,19 @@ func Handler(ctx context.Context, event handler.Event) error {
 }
 
 func FilterRecords(logFile *CloudTrailFile, eventRecord handler.Record) error {
-	for _, record := range logFile.Records {
+	return filterRecords(logFile, eventRecord, nil)
+}
+
+// filterRecords filters the file's records and notifies the kept ones,
+// skipping records progress shows an earlier run already notified.
+func filterRecords(logFile *CloudTrailFile, eventRecord handler.Record, progress *checkpoint.Progress) error {
+	var kept []*action.Action
+	var keptAt []int
+	s3URI := fmt.Sprintf("s3://%s/%s", eventRecord.S3.Bucket.Name, eventRecord.S3.Object.Key)
+	bursts := signInMonitor().Bursts(logFile.Records)
+	overrides := make(map[string]*override.Settings)
+
+	for i, record := range logFile.Records {
 		userIdentity, _ := record["userIdentity"].(map[string]interface{})
 
 		if userIdentity["invokedBy"] == "AWS Internal" {
@@ -83,434 +106,744 @@ func FilterRecords(logFile *CloudTrailFile, eventRecord handler.Record) error {
 			}
 		}
 
-		// codecommit.amazonaws.com
-		if record["eventSource"] == "codecommit.amazonaws.com" {
-			continue
-		}
-
-		// billingconsole.amazonaws.com
-		if record["eventSource"] == "billingconsole.amazonaws.com" {
-			eventName = strings.TrimPrefix(eventName, "AWSPaymentPortalService.")
-			if eventName == "AssessSorChangeImpact" || eventName == "ConvertCurrencies" {
-				continue
-			}
+		// Not all records include the accountId in the userIdentity field.
+		// This was originally identified in cognito-idp:RespondToAuthChallenge
+		// It makes finding the event difficult, so this falls back to another place
+		// where accountId might be listed, making investigation easier
+		var recordAccount string
+		if accountId, ok := userIdentity["accountId"].(string); ok {
+			recordAccount = accountId
 		}
 
-		if record["eventSource"] == "support-console.amazonaws.com" {
-			if strings.HasPrefix(eventName, "Check") {
-				continue
-			}
-			if eventName == "CreateCaseDraft" {
-				// This happens way too many times for a single support case (~40 times)
-				continue
+		if recipientAccountId, ok := record["recipientAccountId"].(string); ok {
+			if record["eventSource"] == "cognito-idp.amazonaws.com" {
+				log.Debugf("Fallback: %s", recipientAccountId)
+			} else {
+				recordAccount = recipientAccountId
 			}
 		}
 
-		// payments.amazonaws.com
-		if record["eventSource"] == "payments.amazonaws.com" {
-			if strings.Contains(eventName, "_Get") ||
-				strings.Contains(eventName, "_BatchGet") ||
-				strings.Contains(eventName, "_List") {
-				continue
-			}
+		ov, ok := overrides[recordAccount]
+		if !ok {
+			ov = accountOverrides(recordAccount)
+			overrides[recordAccount] = ov
 		}
 
-		// q.amazonaws.com
-		if record["eventSource"] == "q.amazonaws.com" {
-			if ec, ok := record["errorCode"].(string); ok {
-				if ec == "AccessDenied" || ec == "ThrottlingException" {
-					if eventName == "StartConversation" {
-						continue
-					}
-					if eventName == "SendMessage" {
-						continue
-					}
-				}
-			}
+		// Root credential use is always kept, sign-ins included
+		rootActivity, isRoot := detect.Root(record)
+		if isRoot && userIdentity["userName"] == nil {
+			userName = "root"
 		}
 
-		switch en := eventName; {
-		// Some events don't match AWS defined standards
-		// So we have to convert the input to Title
-		case strings.HasPrefix(Title(en), "Get"):
-			continue
-		// Some events don't match AWS defined standards
-		// So we have to convert the input to Title
-		case strings.HasPrefix(Title(en), "List"):
-			continue
-		// Some events don't match AWS defined standards
-		// So we have to convert the input to Title
-		case strings.HasPrefix(Title(en), "View"):
-			continue
-		case strings.HasPrefix(en, "Head"):
-			continue
-		case strings.HasPrefix(en, "Describe"):
-			continue
-		case strings.HasPrefix(en, "Test"):
-			continue
-		case strings.HasPrefix(en, "Download"):
-			continue
-		case strings.HasPrefix(en, "Report"):
-			continue
-		case strings.HasPrefix(en, "Refresh"):
-			continue
-		case strings.HasPrefix(en, "Poll"):
-			continue
-		case strings.HasPrefix(en, "Verify"):
-			continue
-		case strings.HasPrefix(en, "Skip"):
-			continue
-		case strings.HasPrefix(en, "Select"):
-			continue
-		case strings.HasPrefix(en, "Count"):
-			continue
-		case strings.HasPrefix(en, "Detect"):
-			continue
-		case strings.HasPrefix(en, "Lookup"):
+		// Repeated failed sign-ins are reported once, on the last attempt
+		burst := bursts[i]
+		if burst != nil && burst.Last != i {
 			continue
+		}
+		signIn := signInMonitor().Check(record)
 
-		case strings.HasPrefix(en, "AdminList"):
-			if record["eventSource"] == "cognito-idp.amazonaws.com" {
+		// Watchlisted calls are kept whatever made them
+		watchRule, watched := watchList().Match(record)
+		if !watched {
+			watchRule, watched = ov.Watched(record)
+		}
+		// So are calls by principals on the always alert list, while those
+		// on the always suppress list are dropped unless something above
+		// keeps them
+		alertRule, alerted := principals().Alert(record)
+		var classifiedBy string
+		if !watched && !alerted && !isRoot && len(signIn) == 0 {
+			if rule, ok := principals().Suppress(record); ok {
+				explain(record, "principal "+rule.Name)
 				continue
 			}
-		case strings.HasSuffix(strings.ToLower(en), "get"):
-			if record["eventSource"] == "cognito-idp.amazonaws.com" {
+			if rule, ok := suppress.Match(suppressions(), record, recordAccount, userName); ok {
+				explain(record, rule.Label())
 				continue
 			}
-		case en == "ConsoleLogin":
-			continue
-		case strings.HasSuffix(en, "VirtualMFADevice"):
-			continue
-		case en == "CheckMfa":
-			continue
-		case en == "InitiateAuth":
-			// cognito-idp.amazonaws.com - Refresh Token
-			if userIdentity["principalId"] == "Anonymous" {
+			if rule, ok := ov.Suppressed(record, recordAccount, userName); ok {
+				explain(record, "override "+rule.Label())
 				continue
 			}
-		case en == "CheckDomainAvailability":
-			continue
-		case en == "AccessKubernetesApi":
-			// eks.amazonaws.com
-			if record["readOnly"] == true {
-				// TODO: Validate that this command only does readOnly events
+			drop, signal := suppressed(record, userIdentity, userName, eventName, ov)
+			if drop {
+				explain(record, signal)
 				continue
 			}
-		case en == "Decrypt":
-			continue
-		case en == "SetTaskStatus":
-			continue
-		case en == "BatchGetQueryExecution":
-			continue
-		case en == "QueryObjects":
-			continue
-		case en == "ValidatePolicy":
-			continue
-		case strings.HasPrefix(en, "StartQuery"):
-			continue
-		case strings.HasPrefix(en, "StopQuery"):
-			continue
-		case strings.HasPrefix(en, "CancelQuery"):
-			continue
-		case strings.HasPrefix(en, "BatchGet"):
-			continue
-		case strings.HasPrefix(en, "Search"):
-			continue
-		case en == "GenerateServiceLastAccessedDetails":
-			continue
-		case en == "REST.GET.OBJECT_LOCK_CONFIGURATION":
-			continue
-		case en == "AssumeRoleWithWebIdentity":
-			continue
+			classifiedBy = signal
+		}
 
-		//cloudwatch.amazonaws.com
-		case strings.HasPrefix(en, "CreateLog"):
-			if rps, ok := record["requestParameters"].(map[string]interface{}); ok {
-				if k, ok := rps["logGroupName"].(string); ok {
-					if k == "RDSOSMetrics" {
-						continue
-					}
-				}
+		a := action.New(record, userName, recordAccount, s3URI)
+		var severityRule string
+		a.Severity, severityRule = severityTable().Score(a)
+		if isRoot {
+			a.Severity, severityRule = severity.Critical, "root"
+			a.Tags = append(a.Tags, detect.RootTag)
+		}
+		if watched {
+			a.Tags = append(a.Tags, watchlist.Tag)
+		}
+		if alerted {
+			a.Tags = append(a.Tags, principal.Tag)
+			if severity.Rank(a.Severity) < severity.Rank(alertRule.Severity) {
+				a.Severity, severityRule = alertRule.Severity, principal.Tag
 			}
-
-		//ec2.amazonaws.com
-		case strings.HasPrefix(en, "CreateNetworkInterface"):
-			if rps, ok := record["requestParameters"].(map[string]interface{}); ok {
-				if k, ok := rps["description"].(string); ok {
-					if strings.HasPrefix(k, "AWS Lambda VPC ENI-") {
-						continue
-					}
-				}
+		}
+		if len(signIn) > 0 {
+			a.Tags = append(a.Tags, detect.SignInTag)
+			a.Detail = detect.Describe(signIn)
+		}
+		if burst != nil {
+			a.Tags = append(a.Tags, detect.BruteForceTag)
+			a.Detail = burst.String()
+			if severity.Rank(a.Severity) < severity.Rank(severity.High) {
+				a.Severity, severityRule = severity.High, detect.BruteForceTag
 			}
+		}
 
-		// elasticfilesystem.amazonaws.com
-		case en == "NewClientConnection":
-			if record["eventSource"] == "elasticfilesystem.amazonaws.com" {
-				// We continue to get rate limited by slack for ANONYMOUS_PRINCIPAL's
-				// if userName != "" { continue } // ANONYMOUS_PRINCIPAL
-				continue
+		if os.Getenv("LOG_FORMAT") == "ocsf" {
+			log.WithField("ocsf", ocsf.FromAction(a)).Info("Event")
+		} else {
+			fields := log.Fields{
+				"user_agent":   record["userAgent"],
+				"client":       a.Client,
+				"via":          a.Via,
+				"event_time":   record["eventTime"],
+				"principal":    userIdentity["principalId"],
+				"user_name":    userName,
+				"event_source": record["eventSource"],
+				"event_name":   record["eventName"],
+				"account_id":   recordAccount,
+				"event_id":     record["eventID"],
+				"s3_uri":       s3URI,
+				"severity":     a.Severity,
 			}
-
-		// quicksight.amazonaws
-		case en == "QueryDatabase":
-			if record["eventSource"] == "quicksight.amazonaws.com" {
-				continue
+			if severityRule != "" {
+				fields["severity_rule"] = severityRule
 			}
-
-		case en == "Federate":
-			if record["eventSource"] == "sso.amazonaws.com" {
-				continue
+			if len(a.Tags) > 0 {
+				fields["tags"] = a.Tags
 			}
-		case en == "Authenticate":
-			if record["eventSource"] == "sso.amazonaws.com" {
-				continue
+			if isRoot {
+				fields["root_activity"] = rootActivity
+				fields["source_ip"] = record["sourceIPAddress"]
 			}
-		case en == "Logout":
-			if record["eventSource"] == "sso.amazonaws.com" {
-				continue
+			if classifiedBy != "" {
+				fields["classified_by"] = classifiedBy
+			}
+			if ov != nil {
+				fields["overrides"] = ov.Names
+			}
+			if watched {
+				fields["watchlist_rule"] = watchRule
 			}
+			if alerted {
+				fields["principal_rule"] = alertRule.Name
+			}
+			if len(signIn) > 0 {
+				fields["signin"] = signIn
+			}
+			if a.Detail != "" {
+				fields["detail"] = a.Detail
+			}
+			log.WithFields(fields).Info("Event")
+		}
 
-		//signin.amazonaws.com
-		case en == "UserAuthentication":
-			if record["eventSource"] == "signin.amazonaws.com" {
-				if aed, ok := record["additionalEventData"].(map[string]interface{}); ok {
-					if k, ok := aed["CredentialType"].(string); ok {
-						if k == "EXTERNAL_IDP" {
-							continue
-						}
-					}
+		kept = append(kept, a)
+		keptAt = append(keptAt, i)
+	}
+
+	notifyErr := notifyFile(kept, keptAt, progress)
+	archiveRecords(s3URI, logFile, kept, keptAt)
+
+	// Leave the object unfinished so a retry delivers what was not sent
+	if notifyErr != nil {
+		return notifyErr
+	}
+	if err := progress.Complete(); err != nil {
+		log.Warnf("checkpoint %s: %v", progress.ID, err)
+	}
+
+	// log.Infof("Scanned %d records", len(logFile.Records))
+	return nil
+}
+
+// explain logs why a record was dropped when EXPLAIN is set, which helps
+// when tuning filters but is too noisy to leave on.
+func explain(record map[string]interface{}, reason string) {
+	if os.Getenv("EXPLAIN") == "" {
+		return
+	}
+	log.WithFields(log.Fields{
+		"event_id":      record["eventID"],
+		"event_source":  record["eventSource"],
+		"event_name":    record["eventName"],
+		"user_agent":    record["userAgent"],
+		"suppressed_by": reason,
+	}).Info("Suppressed")
+}
+
+// droppedNames are event names that are always noise, whichever service
+// they come from.
+var droppedNames = matcher.New().
+	Exact(
+		"ConsoleLogin",
+		"CheckMfa",
+		"CheckDomainAvailability",
+		"Decrypt",
+		"SetTaskStatus",
+		"BatchGetQueryExecution",
+		"QueryObjects",
+		"ValidatePolicy",
+		"GenerateServiceLastAccessedDetails",
+		"REST.GET.OBJECT_LOCK_CONFIGURATION",
+		"AssumeRoleWithWebIdentity",
+		// cloudshell.amazonaws.com
+		"SendHeartBeat",
+		"CreateEnvironment",
+		"CreateSession",
+		"DeleteEnvironment",
+		"RedeemCode",
+		"startEnvironment",
+		"stopEnvironment",
+		"PutCredentials",
+	).
+	Prefix("StartQuery", "StopQuery", "CancelQuery", "BatchGet", "Search").
+	Suffix("VirtualMFADevice")
+
+// lettersOnly fingerprints the generated user names Step Functions submits
+// Batch jobs as.
+var lettersOnly = regexp.MustCompile(`^[a-zA-Z]+$`)
+
+// signalFilter labels records dropped by one of the hand written filters
+// below rather than by a classify decision.
+const signalFilter = "filter"
+
+// suppressed reports whether a record is noise: a read-only or automated
+// call, or one that was not made from the console. It also returns the
+// signal the decision was based on. Filters whose signal the account's
+// overrides disable are skipped.
+func suppressed(record, userIdentity map[string]interface{}, userName, eventName string, ov *override.Settings) (bool, string) {
+	// Insights are CloudTrail's own analysis, not calls anyone made
+	if category, signal := classify.Category(record); category == classify.Insight && !ov.FilterDisabled(signal) {
+		return true, signal
+	}
+
+	if readOnly := classify.ReadOnly(record); readOnly.Value && !ov.FilterDisabled(readOnly.Signal) {
+		return true, readOnly.Signal
+	}
+
+	if !ov.FilterDisabled(signalFilter) && filtered(record, userIdentity, userName, eventName) {
+		return true, signalFilter
+	}
+
+	console := classify.Console(record)
+	if !console.Value && !ov.FilterDisabled(console.Signal) {
+		return true, console.Signal
+	}
+	return false, console.Signal
+}
+
+// filtered reports whether one of the hand written filters for particular
+// services and calls drops the record.
+func filtered(record, userIdentity map[string]interface{}, userName, eventName string) bool {
+	// codecommit.amazonaws.com
+	if record["eventSource"] == "codecommit.amazonaws.com" {
+		return true
+	}
+
+	// billingconsole.amazonaws.com
+	if record["eventSource"] == "billingconsole.amazonaws.com" {
+		eventName = strings.TrimPrefix(eventName, "AWSPaymentPortalService.")
+		if eventName == "AssessSorChangeImpact" || eventName == "ConvertCurrencies" {
+			return true
+		}
+	}
+
+	if record["eventSource"] == "support-console.amazonaws.com" {
+		if strings.HasPrefix(eventName, "Check") {
+			return true
+		}
+		if eventName == "CreateCaseDraft" {
+			// This happens way too many times for a single support case (~40 times)
+			return true
+		}
+	}
+
+	// payments.amazonaws.com
+	if record["eventSource"] == "payments.amazonaws.com" {
+		if strings.Contains(eventName, "_Get") ||
+			strings.Contains(eventName, "_BatchGet") ||
+			strings.Contains(eventName, "_List") {
+			return true
+		}
+	}
+
+	// q.amazonaws.com
+	if record["eventSource"] == "q.amazonaws.com" {
+		if ec, ok := record["errorCode"].(string); ok {
+			if ec == "AccessDenied" || ec == "ThrottlingException" {
+				if eventName == "StartConversation" {
+					return true
+				}
+				if eventName == "SendMessage" {
+					return true
 				}
 			}
+		}
+	}
 
-		//cloudshell.amazonaws.com
-		case en == "SendHeartBeat":
-			continue
-		//cloudshell.amazonaws.com
-		case en == "CreateEnvironment":
-			continue
-		//cloudshell.amazonaws.com
-		case en == "CreateSession":
-			continue
-		//cloudshell.amazonaws.com
-		case en == "DeleteEnvironment":
-			continue
-		//cloudshell.amazonaws.com
-		case en == "RedeemCode":
-			continue
-		//cloudshell.amazonaws.com
-		case en == "startEnvironment":
-			continue
-		//cloudshell.amazonaws.com
-		case en == "stopEnvironment":
-			continue
-		//cloudshell.amazonaws.com
-		case en == "PutCredentials":
-			continue
+	switch en := eventName; {
+	case strings.HasPrefix(en, "AdminList"):
+		if record["eventSource"] == "cognito-idp.amazonaws.com" {
+			return true
+		}
+	case len(en) >= 3 && strings.EqualFold(en[len(en)-3:], "get"):
+		if record["eventSource"] == "cognito-idp.amazonaws.com" {
+			return true
+		}
+	case droppedNames.Match(en):
+		return true
+	case en == "InitiateAuth":
+		// cognito-idp.amazonaws.com - Refresh Token
+		if userIdentity["principalId"] == "Anonymous" {
+			return true
+		}
 
-		//ssm.amazonaws.com
-		case strings.HasSuffix(en, "Session"):
-			// ssm:StartSession
-			// ssm:ResumeSession
-			// ssm:TerminateSession
-			if record["eventSource"] == "ssm.amazonaws.com" {
-				continue
+	//cloudwatch.amazonaws.com
+	case strings.HasPrefix(en, "CreateLog"):
+		if rps, ok := record["requestParameters"].(map[string]interface{}); ok {
+			if k, ok := rps["logGroupName"].(string); ok {
+				if k == "RDSOSMetrics" {
+					return true
+				}
 			}
+		}
 
-		// "logs.amazonaws.com"
-		case en == "FilterLogEvents":
-			if record["eventSource"] == "logs.amazonaws.com" {
-				continue
-			}
-		case en == "PutQueryDefinition":
-			if record["eventSource"] == "logs.amazonaws.com" {
-				continue
+	//ec2.amazonaws.com
+	case strings.HasPrefix(en, "CreateNetworkInterface"):
+		if rps, ok := record["requestParameters"].(map[string]interface{}); ok {
+			if k, ok := rps["description"].(string); ok {
+				if strings.HasPrefix(k, "AWS Lambda VPC ENI-") {
+					return true
+				}
 			}
+		}
 
-		// s3.amazonaws.com
-		case en == "PutObject":
-			// Fingerprinting on KeyPath for LB Logs
-			// Objects are originating outside our account with these account ids.
-			// https://docs.aws.amazon.com/elasticloadbalancing/latest/classic/enable-access-logs.html
-			if userIdentity["type"] == "AWSAccount" {
-				if rps, ok := record["requestParameters"].(map[string]interface{}); ok {
-					if k, ok := rps["key"].(string); ok {
-						if strings.Contains(k, "AWSLogs/") && strings.Contains(k, "/elasticloadbalancing/") {
-							continue
-						}
+	// elasticfilesystem.amazonaws.com
+	case en == "NewClientConnection":
+		if record["eventSource"] == "elasticfilesystem.amazonaws.com" {
+			// We continue to get rate limited by slack for ANONYMOUS_PRINCIPAL's
+			// if userName != "" { continue } // ANONYMOUS_PRINCIPAL
+			return true
+		}
+
+	// quicksight.amazonaws
+	case en == "QueryDatabase":
+		if record["eventSource"] == "quicksight.amazonaws.com" {
+			return true
+		}
+
+	case en == "Federate":
+		if record["eventSource"] == "sso.amazonaws.com" {
+			return true
+		}
+	case en == "Authenticate":
+		if record["eventSource"] == "sso.amazonaws.com" {
+			return true
+		}
+	case en == "Logout":
+		if record["eventSource"] == "sso.amazonaws.com" {
+			return true
+		}
+
+	//signin.amazonaws.com
+	case en == "UserAuthentication":
+		if record["eventSource"] == "signin.amazonaws.com" {
+			if aed, ok := record["additionalEventData"].(map[string]interface{}); ok {
+				if k, ok := aed["CredentialType"].(string); ok {
+					if k == "EXTERNAL_IDP" {
+						return true
 					}
 				}
 			}
+		}
+
+	//ssm.amazonaws.com
+	case strings.HasSuffix(en, "Session"):
+		// ssm:StartSession
+		// ssm:ResumeSession
+		// ssm:TerminateSession
+		if record["eventSource"] == "ssm.amazonaws.com" {
+			return true
+		}
+
+	// "logs.amazonaws.com"
+	case en == "FilterLogEvents":
+		if record["eventSource"] == "logs.amazonaws.com" {
+			return true
+		}
+	case en == "PutQueryDefinition":
+		if record["eventSource"] == "logs.amazonaws.com" {
+			return true
+		}
 
-		// iam.amazonaws.com
-		case strings.HasPrefix(en, "AssumeRole"):
-			if record["userAgent"] == "Coral/Netty4" {
-				switch userIdentity["invokedBy"] {
-				case
-					"ecs-tasks.amazonaws.com",
-					"ec2.amazonaws.com",
-					"monitoring.rds.amazonaws.com",
-					"lambda.amazonaws.com":
-					continue
+	// s3.amazonaws.com
+	case en == "PutObject":
+		// Fingerprinting on KeyPath for LB Logs
+		// Objects are originating outside our account with these account ids.
+		// https://docs.aws.amazon.com/elasticloadbalancing/latest/classic/enable-access-logs.html
+		if userIdentity["type"] == "AWSAccount" {
+			if rps, ok := record["requestParameters"].(map[string]interface{}); ok {
+				if k, ok := rps["key"].(string); ok {
+					if strings.Contains(k, "AWSLogs/") && strings.Contains(k, "/elasticloadbalancing/") {
+						return true
+					}
 				}
 			}
-			if userIdentity["type"] == "SAMLUser" {
-				continue
+		}
+
+	// iam.amazonaws.com
+	case strings.HasPrefix(en, "AssumeRole"):
+		if record["userAgent"] == "Coral/Netty4" {
+			switch userIdentity["invokedBy"] {
+			case
+				"ecs-tasks.amazonaws.com",
+				"ec2.amazonaws.com",
+				"monitoring.rds.amazonaws.com",
+				"lambda.amazonaws.com":
+				return true
 			}
-			if userIdentity["type"] == "AWSAccount" {
-				continue
+		}
+		if userIdentity["type"] == "SAMLUser" {
+			return true
+		}
+		if userIdentity["type"] == "AWSAccount" {
+			return true
+		}
+
+	// batch.amazonaws.com
+	case en == "SubmitJob":
+		// Fingerprinting on userName, Length and Contents
+		// When called from AWS Step Functions the UA appears too similar to console actions
+		if len(userName) == 32 {
+			if lettersOnly.MatchString(userName) {
+				return true
 			}
+		}
+	}
 
-		// batch.amazonaws.com
-		case en == "SubmitJob":
-			// Fingerprinting on userName, Length and Contents
-			// When called from AWS Step Functions the UA appears too similar to console actions
-			if len(userName) == 32 {
-				matched, _ := regexp.MatchString(`^[a-zA-Z]+$`, userName)
-				if matched {
-					continue
-				}
+	return false
+}
+
+// notifyFile notifies a file's kept actions, which were kept at the record
+// indexes in keptAt. Actions are sent in batches with a checkpoint after each,
+// so a run that dies mid-file is resumed after the last notified record.
+// Digests need the whole file, so DIGEST_MODE and email destinations send a
+// single batch. It stops at the first batch that was not fully delivered or
+// dead lettered and returns its error, leaving the checkpoint before it.
+func notifyFile(kept []*action.Action, keptAt []int, progress *checkpoint.Progress) error {
+	resume := progress.Resume()
+	var pending []*action.Action
+	var pendingAt []int
+	for i, a := range kept {
+		if keptAt[i] >= resume {
+			pending = append(pending, a)
+			pendingAt = append(pendingAt, keptAt[i])
+		}
+	}
+	if resume > 0 {
+		log.Infof("Resuming %s at record %d, %d of %d kept records left", progress.ID, resume, len(pending), len(kept))
+	}
+
+	batch := len(pending)
+	if app, err := loadApp(); err == nil && progress != nil && os.Getenv("DIGEST_MODE") == "" && !summarizes(app) {
+		batch = app.checkpointBatch
+	}
+
+	for start := 0; start < len(pending); start += batch {
+		end := start + batch
+		if end > len(pending) {
+			end = len(pending)
+		}
+
+		if err := notifyActions(pending[start:end]); err != nil {
+			return err
+		}
+		if err := progress.Advance(pendingAt[end-1] + 1); err != nil {
+			log.Warnf("checkpoint %s: %v", progress.ID, err)
+		}
+	}
+	return nil
+}
+
+// summarizes reports whether any destination summarizes each batch it is
+// sent.
+func summarizes(app *app) bool {
+	for _, d := range app.config.Destinations {
+		if d.Summarizes() {
+			return true
+		}
+	}
+	return false
+}
+
+// notifyActions routes kept actions to their destinations and delivers them,
+// grouping each destination's actions into digests when DIGEST_MODE is set.
+// It returns an error when any destination was left with actions that were
+// neither delivered nor dead lettered, so that Lambda retries the file, but
+// only when a checkpoint or dedup store keeps the retry from repeating what
+// was delivered. Otherwise failures are logged and the file is done.
+func notifyActions(kept []*action.Action) error {
+	if len(kept) == 0 {
+		return nil
+	}
+
+	app, err := loadApp()
+	if err != nil {
+		return err
+	}
+	cfg := app.config
+
+	mode, err := digest.ParseMode(os.Getenv("DIGEST_MODE"))
+	if err != nil {
+		log.Warn(err)
+	}
+
+	threshold := digest.DefaultThreshold
+	if v, err := strconv.Atoi(getEnv("DIGEST_THRESHOLD", "")); err == nil {
+		threshold = v
+	}
+
+	var targets []route.Target
+	routed := make(map[route.Target][]*action.Action)
+	overrides := make(map[string]*override.Settings)
+	for _, a := range kept {
+		ov, ok := overrides[a.AccountID]
+		if !ok {
+			ov = accountOverrides(a.AccountID)
+			overrides[a.AccountID] = ov
+		}
+		if !ov.Notifies(a) {
+			continue
+		}
+
+		for _, t := range ov.Table(&cfg.Routes).Route(a, cfg.Accounts) {
+			if _, ok := routed[t]; !ok {
+				targets = append(targets, t)
 			}
+			routed[t] = append(routed[t], a)
 		}
+	}
 
-		if usa, ok := record["userAgent"]; ok {
-			// This switch case is backwards from all the others.
-			// We are targeting specific UserAgents that are considered
-			// Console Actions
-			switch ua := usa.(string); {
-			case ua == "console.amazonaws.com":
-				break
-			case ua == "signin.amazonaws.com":
-				break
-			case ua == "Coral/Jakarta":
-				break
-			case ua == "Coral/Netty4":
-				break
-			case ua == "AWS CloudWatch Console":
-				break
-			case strings.HasPrefix(ua, "AWS Signin"):
-				break
-			case strings.HasPrefix(ua, "S3Console/"):
-				break
-			case strings.HasPrefix(ua, "[S3Console"):
-				break
-			case strings.HasPrefix(ua, "Mozilla/"):
-				break
-			case strings.HasPrefix(ua, "[Mozilla/"):
-				break
-			case matchString("console.*.amazonaws.com", ua):
-				break
-			case matchString("signin.*.amazonaws.com", ua):
-				break
-
-			// fsx.amazonaws.com uses AWS Internal
-			// Not sure if we need to filter for just that
-			// or if this is trapping more than it should.
-			// AWS Internal, aws-internal, aws-sdk-ruby aws-internal (...)
-			case matchString("(?i)aws[\\s-]internal", ua):
-				if matchString("^aws-vpc-flow-logs", ua) {
-					continue
-				}
-				break
-			default:
-				// If we can't determine the UserAgent
-				// we consider it most likely a CLI or IaC Tool.
-				continue
+	var undelivered []string
+	for _, t := range targets {
+		n, err := app.notifiers.Get(t.Destination, t.Channel)
+		if err != nil {
+			log.Error(err)
+			undelivered = append(undelivered, t.Destination)
+			continue
+		}
+
+		actions, pending := claim(app, t, routed[t])
+		if len(pending) > 0 {
+			// Fail so a retry sends them if the other invocation does not
+			log.Warnf("%d events for %s are being sent by another invocation", len(pending), t.Destination)
+			undelivered = append(undelivered, t.Destination)
+		}
+		if len(actions) == 0 {
+			continue
+		}
+
+		groups := digest.Build(actions, mode, threshold)
+		var failed []*action.Action
+		err = n.Notify(groups)
+		if err != nil {
+			log.Error(err)
+			// Dead lettered actions count as delivered, the replay command
+			// delivers them
+			if app.deadLetters == nil || !deadLetter(app, t, groups, err) {
+				failed = notify.Undelivered(groups, err)
 			}
 		}
+		settle(app, t, actions, failed)
+		if len(failed) > 0 && len(pending) == 0 {
+			undelivered = append(undelivered, t.Destination)
+		}
+	}
 
-		var errorCode string
-		if ec, ok := record["errorCode"].(string); ok {
-			errorCode = fmt.Sprintf(" - `%s`", ec)
+	if len(undelivered) > 0 && (app.checkpoints != nil || app.dedup != nil) {
+		return fmt.Errorf("undelivered notifications for %s", strings.Join(undelivered, ", "))
+	}
+	return nil
+}
+
+// claim drops the actions an earlier invocation already delivered to t, and
+// returns separately those another invocation is still sending. Actions are
+// delivered anyway when the dedup store cannot be reached.
+func claim(app *app, t route.Target, actions []*action.Action) (claimed, pending []*action.Action) {
+	if app.dedup == nil {
+		return actions, nil
+	}
+
+	for _, a := range actions {
+		if a.EventID == "" {
+			claimed = append(claimed, a)
+			continue
 		}
 
-		// Not all records include the accountId in the userIdentity field.
-		// This was originally identified in cognito-idp:RespondToAuthChallenge
-		// It makes finding the event difficult, so this falls back to another place
-		// where accountId might be listed, making investigation easier
-		var recordAccount string
-		if accountId, ok := userIdentity["accountId"].(string); ok {
-			recordAccount = accountId
+		ok, err := app.dedup.Claim(dedup.Key(a.EventID, t.Destination, t.Channel))
+		if err == dedup.ErrPending {
+			pending = append(pending, a)
+			continue
+		}
+		if err != nil {
+			log.Warnf("dedup %s: %v", a.EventID, err)
+			ok = true
+		}
+		if ok {
+			claimed = append(claimed, a)
+		} else {
+			log.Debugf("Skipping %s, already sent to %s", a.EventID, t.Destination)
 		}
+	}
+	return claimed, pending
+}
 
-		if recipientAccountId, ok := record["recipientAccountId"].(string); ok {
-			if record["eventSource"] == "cognito-idp.amazonaws.com" {
-				fmt.Sprintf("Fallback: %s", recipientAccountId)
-			} else {
-				recordAccount = recipientAccountId
+// settle records the outcome of sending claimed actions to t. Delivered
+// actions are remembered, and failed ones are released so a retry of the
+// file can send them.
+func settle(app *app, t route.Target, claimed, failed []*action.Action) {
+	if app.dedup == nil {
+		return
+	}
+
+	isFailed := make(map[*action.Action]bool, len(failed))
+	for _, a := range failed {
+		isFailed[a] = true
+	}
+	for _, a := range claimed {
+		if a.EventID == "" {
+			continue
+		}
+
+		key := dedup.Key(a.EventID, t.Destination, t.Channel)
+		var err error
+		if isFailed[a] {
+			err = app.dedup.Release(key)
+		} else {
+			err = app.dedup.Deliver(key)
+		}
+		if err != nil {
+			log.Warnf("dedup %s: %v", a.EventID, err)
+		}
+	}
+}
+
+// deadLetter persists the messages a destination failed to deliver so they
+// can be replayed later. It reports whether every message was stored.
+func deadLetter(app *app, t route.Target, groups []*digest.Group, err error) bool {
+	stored := true
+	for _, e := range deadletter.Entries(t.Destination, t.Channel, groups, err) {
+		if err := app.deadLetters.Put(e); err != nil {
+			log.Errorf("dead letter %s: %v", e.ID, err)
+			stored = false
+		}
+	}
+	return stored
+}
+
+// archiveRecords writes the file's kept, and optionally dropped, records to
+// the S3 archive when one is configured.
+func archiveRecords(s3URI string, logFile *CloudTrailFile, kept []*action.Action, keptAt []int) {
+	app, err := loadApp()
+	if err != nil || app.archiver == nil {
+		return
+	}
+
+	var dropped []map[string]interface{}
+	if app.archiver.IncludeDropped {
+		next := 0
+		for i, record := range logFile.Records {
+			if next < len(keptAt) && keptAt[next] == i {
+				next++
+				continue
 			}
+			dropped = append(dropped, record)
 		}
+	}
 
-		log.WithFields(log.Fields{
-			"user_agent":   record["userAgent"],
-			"event_time":   record["eventTime"],
-			"principal":    userIdentity["principalId"],
-			"user_name":    userName,
-			"event_source": record["eventSource"],
-			"event_name":   record["eventName"],
-			"account_id":   recordAccount,
-			"event_id":     record["eventID"],
-			"s3_uri":       fmt.Sprintf("s3://%s/%s", eventRecord.S3.Bucket.Name, eventRecord.S3.Object.Key),
-		}).Info("Event")
-
-		if webhookUrl, ok := os.LookupEnv("SLACK_WEBHOOK"); ok {
-			slackName := getEnv(
-				fmt.Sprintf("SLACK_NAME_%s", userIdentity["accountId"]),
-				getEnv("SLACK_NAME", fmt.Sprintf("%s", recordAccount)),
-			)
-			slackBody := fmt.Sprintf(`
-{
-  "channel": "%s",
-  "text": "%s | %s | %s",
-  "blocks": [
-    {
-      "type": "section",
-      "text": {
-        "type": "mrkdwn",
-        "text": "*%s* - %s%s"
-      }
-    },
-    {
-      "type": "context",
-      "elements": [
-        {
-          "type": "mrkdwn",
-          "text": "%s"
-        },
-        {
-          "type": "mrkdwn",
-          "text": "%s"
-        },
-        {
-          "type": "mrkdwn",
-          "text": "<https://console.aws.amazon.com/cloudtrail/home?region=%s#/events?EventId=%s|%s>"
-        }
-      ]
-    }
-  ]
+	if err := app.archiver.Write(s3URI, kept, dropped); err != nil {
+		log.Error(err)
+	}
 }
-`,
-				os.Getenv("SLACK_CHANNEL"),
-				slackName,
-				record["eventName"],
-				userName,
-				record["eventName"],
-				record["eventSource"],
-				errorCode,
-				slackName,
-				userName,
-				record["awsRegion"],
-				record["eventID"],
-				record["eventTime"])
-
-			err := SendSlackNotification(webhookUrl, []byte(slackBody))
+
+// app holds the state built from configuration once per container.
+type app struct {
+	config      *config.Config
+	notifiers   *notify.Set
+	archiver    *archive.Archiver
+	deadLetters deadletter.Store
+	dedup       dedup.Store
+
+	checkpoints     checkpoint.Store
+	checkpointBatch int
+}
+
+var (
+	appOnce  sync.Once
+	appState *app
+	appErr   error
+)
+
+// loadApp reads CONFIG_FILE once per container.
+func loadApp() (*app, error) {
+	appOnce.Do(func() {
+		cfg, err := config.FromEnv()
+		if err != nil {
+			appErr = err
+			return
+		}
+
+		a := &app{
+			config:    cfg,
+			notifiers: notify.NewSet(cfg.Destinations, cfg.Accounts),
+		}
+		if cfg.Archive != nil {
+			a.archiver, err = archive.New(*cfg.Archive)
 			if err != nil {
-				log.Debugln(slackBody)
-				log.Debug(err)
+				appErr = fmt.Errorf("archive: %v", err)
+				return
 			}
 		}
-	}
-	// log.Infof("Scanned %d records", len(logFile.Records))
-	return nil
+		if cfg.DeadLetter != nil {
+			a.deadLetters, err = deadletter.New(*cfg.DeadLetter)
+			if err != nil {
+				appErr = fmt.Errorf("dead_letter: %v", err)
+				return
+			}
+		}
+		if cfg.Dedup != nil {
+			a.dedup, err = dedup.New(*cfg.Dedup)
+			if err != nil {
+				appErr = fmt.Errorf("dedup: %v", err)
+				return
+			}
+		}
+		if cfg.Checkpoint != nil {
+			a.checkpoints, err = checkpoint.New(*cfg.Checkpoint)
+			if err != nil {
+				appErr = fmt.Errorf("checkpoint: %v", err)
+				return
+			}
+			a.checkpointBatch = cfg.Checkpoint.BatchSize
+			if a.checkpointBatch <= 0 {
+				a.checkpointBatch = checkpoint.DefaultBatchSize
+			}
+		}
+		appState = a
+	})
+	return appState, appErr
 }
 
 func Stream(eventRecord handler.Record) error {
@@ -521,6 +854,15 @@ func Stream(eventRecord handler.Record) error {
 
 	log.Debugf("Reading %s from %s with client config of %+v", s3Object, s3Bucket, s3Client.Config)
 
+	// The event usually carries the ETag, which lets finished objects be
+	// skipped without fetching them
+	etag := eventRecord.S3.Object.ETag
+	progress := openProgress(s3Bucket, s3Object, etag)
+	if progress.Done() {
+		log.Infof("Skipping %s, already processed", progress.ID)
+		return nil
+	}
+
 	obj, err := fetchLogFromS3(s3Client, s3Bucket, s3Object)
 	if err != nil {
 		return fmt.Errorf("%v: %v", s3Object, err)
@@ -529,12 +871,21 @@ func Stream(eventRecord handler.Record) error {
 		return nil
 	}
 
+	if etag == "" {
+		progress = openProgress(s3Bucket, s3Object, aws.StringValue(obj.ETag))
+		if progress.Done() {
+			obj.Body.Close()
+			log.Infof("Skipping %s, already processed", progress.ID)
+			return nil
+		}
+	}
+
 	logFile, err := readLogFile(obj)
 	if err != nil {
 		return fmt.Errorf("%v: %v", s3Object, err)
 	}
 
-	err = FilterRecords(logFile, eventRecord)
+	err = filterRecords(logFile, eventRecord, progress)
 	if err != nil {
 		return fmt.Errorf("%v: %v", s3Object, err)
 	}
@@ -542,6 +893,82 @@ func Stream(eventRecord handler.Record) error {
 	return nil
 }
 
+// severityTable returns the configured severity rules, falling back to the
+// builtin rules when the configuration cannot be loaded.
+func severityTable() *severity.Table {
+	app, err := loadApp()
+	if err != nil {
+		return &severity.Table{}
+	}
+	return &app.config.Severity
+}
+
+// watchList returns the configured watchlist, falling back to the builtin
+// rules when the configuration cannot be loaded.
+func watchList() *watchlist.List {
+	app, err := loadApp()
+	if err != nil {
+		return &watchlist.List{}
+	}
+	return &app.config.Watchlist
+}
+
+// accountOverrides returns the overrides that apply to an account, or nil
+// when there are none or the configuration cannot be loaded.
+func accountOverrides(id string) *override.Settings {
+	app, err := loadApp()
+	if err != nil {
+		return nil
+	}
+	return override.For(app.config.Overrides, id, app.config.Accounts)
+}
+
+// suppressions returns the configured suppression rules and windows.
+func suppressions() []suppress.Rule {
+	app, err := loadApp()
+	if err != nil {
+		return nil
+	}
+	return app.config.Suppressions
+}
+
+// principals returns the configured principal lists, which are empty when
+// the configuration cannot be loaded.
+func principals() *principal.Lists {
+	app, err := loadApp()
+	if err != nil {
+		return &principal.Lists{}
+	}
+	return &app.config.Principals
+}
+
+// signInMonitor returns the configured sign-in monitor, which is disabled
+// when the configuration cannot be loaded.
+func signInMonitor() *detect.SignIn {
+	app, err := loadApp()
+	if err != nil {
+		return &detect.SignIn{}
+	}
+	return &app.config.SignIn
+}
+
+// openProgress loads the checkpoint for an object version. It returns nil,
+// which processes the whole object, when checkpoints are not configured, the
+// ETag is unknown or the store cannot be read.
+func openProgress(bucket, key, etag string) *checkpoint.Progress {
+	app, err := loadApp()
+	if err != nil || app.checkpoints == nil || etag == "" {
+		return nil
+	}
+
+	progress, err := checkpoint.Open(app.checkpoints, bucket, key, etag)
+	if err != nil {
+		log.Warnf("checkpoint s3://%s/%s: %v", bucket, key, err)
+		return nil
+	}
+	return progress
+}
+
 func fetchLogFromS3(s3Client *s3.S3, s3Bucket string, s3Object string) (*s3.GetObjectOutput, error) {
 	logInput := &s3.GetObjectInput{
 		Bucket: aws.String(s3Bucket),
@@ -593,39 +1020,11 @@ func readLogFile(object *s3.GetObjectOutput) (*CloudTrailFile, error) {
 	return &logFile, nil
 }
 
-func matchString(m, s string) bool {
-	v, _ := regexp.MatchString(m, s)
-	return v
-}
-
 func prettyPrint(i interface{}) string {
 	s, _ := json.MarshalIndent(i, "", "  ")
 	return string(s)
 }
 
-func SendSlackNotification(webhookUrl string, slackBody []byte) error {
-
-	req, err := http.NewRequest(http.MethodPost, webhookUrl, bytes.NewBuffer(slackBody))
-	if err != nil {
-		return err
-	}
-
-	req.Header.Add("Content-Type", "application/json")
-
-	client := &http.Client{Timeout: 10 * time.Second}
-	resp, err := client.Do(req)
-	if err != nil {
-		return err
-	}
-
-	buf := new(bytes.Buffer)
-	buf.ReadFrom(resp.Body)
-	if buf.String() != "ok" {
-		return errors.New(fmt.Sprintf("Non-ok response returned from Slack: %s", buf.String()))
-	}
-	return nil
-}
-
 func getEnv(key, fallback string) string {
 	if value, ok := os.LookupEnv(key); ok {
 		if value == "" {
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"testing"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/handler"
+	log "github.com/sirupsen/logrus"
+)
+
+// syntheticUserAgents mixes the clients seen in a busy account, most of
+// which are filtered out.
+var syntheticUserAgents = []string{
+	"aws-cli/2.13.0 Python/3.11.4 Darwin/22.6.0 exe/x86_64 prompt/off command/ec2.describe-instances",
+	"aws-sdk-go/1.44.122 (go1.19.3; linux; amd64) APN/1.0 HashiCorp/1.0 Terraform/1.3.7 (+https://www.terraform.io)",
+	"Boto3/1.26.0 Python/3.10.8 Linux/5.10 Botocore/1.29.0",
+	"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+	"console.ec2.amazonaws.com",
+	"aws-internal/3 aws-sdk-java/1.12.488 Linux/5.10 OpenJDK_64-Bit_Server_VM/25.372-b08 java/1.8.0_372",
+	"lambda.amazonaws.com",
+	"Coral/Netty4",
+}
+
+var syntheticEvents = []struct {
+	source, name string
+}{
+	{"ec2.amazonaws.com", "DescribeInstances"},
+	{"s3.amazonaws.com", "getBucketAcl"},
+	{"iam.amazonaws.com", "ListRoles"},
+	{"kms.amazonaws.com", "Decrypt"},
+	{"sts.amazonaws.com", "AssumeRole"},
+	{"ec2.amazonaws.com", "RunInstances"},
+	{"ec2.amazonaws.com", "CreateTags"},
+	{"iam.amazonaws.com", "PutUserPolicy"},
+	{"batch.amazonaws.com", "SubmitJob"},
+	{"logs.amazonaws.com", "StartQuery"},
+	{"cloudshell.amazonaws.com", "SendHeartBeat"},
+	{"s3.amazonaws.com", "PutObject"},
+}
+
+// syntheticTrail builds a CloudTrail file of n records cycling through the
+// events and user agents above. Every other record omits readOnly so both
+// the authoritative and heuristic paths are exercised.
+func syntheticTrail(n int) *CloudTrailFile {
+	file := &CloudTrailFile{}
+	for i := 0; i < n; i++ {
+		event := syntheticEvents[i%len(syntheticEvents)]
+		record := map[string]interface{}{
+			"eventVersion": "1.08",
+			"userIdentity": map[string]interface{}{
+				"type":        "AssumedRole",
+				"principalId": fmt.Sprintf("AROAEXAMPLE:user%d", i%37),
+				"arn":         fmt.Sprintf("arn:aws:sts::123456789012:assumed-role/dev/user%d", i%37),
+				"accountId":   "123456789012",
+			},
+			"eventTime":          "2021-05-14T19:03:40Z",
+			"eventSource":        event.source,
+			"eventName":          event.name,
+			"awsRegion":          "us-east-1",
+			"sourceIPAddress":    "203.0.113.7",
+			"userAgent":          syntheticUserAgents[(i/len(syntheticEvents))%len(syntheticUserAgents)],
+			"requestParameters":  map[string]interface{}{},
+			"eventID":            fmt.Sprintf("00000000-0000-0000-0000-%012d", i),
+			"eventType":          "AwsApiCall",
+			"recipientAccountId": "123456789012",
+		}
+		if i%2 == 0 {
+			record["readOnly"] = false
+			record["managementEvent"] = true
+			record["eventCategory"] = "Management"
+		}
+		file.Records = append(file.Records, record)
+	}
+	return file
+}
+
+func BenchmarkSuppressed(b *testing.B) {
+	file := syntheticTrail(10000)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		for _, record := range file.Records {
+			userIdentity := record["userIdentity"].(map[string]interface{})
+			suppressed(record, userIdentity, "user", record["eventName"].(string), nil)
+		}
+	}
+}
+
+func BenchmarkFilterRecords(b *testing.B) {
+	out := log.StandardLogger().Out
+	log.SetOutput(ioutil.Discard)
+	defer log.SetOutput(out)
+
+	file := syntheticTrail(10000)
+	record := handler.Record{AWSRegion: "us-east-1"}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		FilterRecords(file, record)
+	}
+}
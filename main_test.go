@@ -6,11 +6,27 @@ import (
 	"fmt"
 	"io/ioutil"
 	"log"
+	"net/http"
+	"net/http/httptest"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/checkpoint"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/classify"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/config"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/dedup"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/detect"
 	"github.com/Techcadia/cloudtrail-console-actions/pkg/handler"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/notify"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/override"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/route"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/watchlist"
 	"github.com/aws/aws-lambda-go/events"
 	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/sirupsen/logrus"
+	logtest "github.com/sirupsen/logrus/hooks/test"
 )
 
 func TestReadExamples(t *testing.T) {
@@ -78,3 +94,263 @@ func testReadLogFile(t *testing.T, testData []byte, path string) error {
 
 	return nil
 }
+
+// keptEvents runs records through filterRecords and returns the fields of
+// the Event line logged for each record it kept.
+func keptEvents(t *testing.T, records ...map[string]interface{}) []logrus.Fields {
+	logger := logrus.StandardLogger()
+	hooks := logger.ReplaceHooks(make(logrus.LevelHooks))
+	defer logger.ReplaceHooks(hooks)
+	hook := logtest.NewLocal(logger)
+
+	if err := filterRecords(&CloudTrailFile{Records: records}, handler.Record{}, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	var kept []logrus.Fields
+	for _, e := range hook.AllEntries() {
+		if e.Message == "Event" {
+			kept = append(kept, e.Data)
+		}
+	}
+	return kept
+}
+
+func hasTag(fields logrus.Fields, tag string) bool {
+	tags, _ := fields["tags"].([]string)
+	for _, t := range tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+func TestWatchlistBypassesUserAgent(t *testing.T) {
+	record := map[string]interface{}{
+		"eventID":      "1",
+		"eventSource":  "cloudtrail.amazonaws.com",
+		"eventName":    "StopLogging",
+		"userAgent":    "aws-cli/2.2.5 Python/3.8.8 Darwin/20.4.0 exe/x86_64 prompt/off command/cloudtrail.stop-logging",
+		"userIdentity": map[string]interface{}{"type": "IAMUser", "userName": "ci"},
+	}
+	userIdentity := record["userIdentity"].(map[string]interface{})
+
+	if drop, signal := suppressed(record, userIdentity, "ci", "StopLogging", nil); !drop || signal != classify.SignalUserAgent {
+		t.Fatal("expected the CLI user agent to be suppressed")
+	}
+
+	// Another CLI call that is not watchlisted is still dropped
+	other := map[string]interface{}{
+		"eventID":      "2",
+		"eventSource":  "ec2.amazonaws.com",
+		"eventName":    "RunInstances",
+		"userAgent":    record["userAgent"],
+		"userIdentity": userIdentity,
+	}
+	kept := keptEvents(t, record, other)
+	if len(kept) != 1 || kept[0]["event_id"] != "1" {
+		t.Fatalf("expected only StopLogging to be kept, got %v", kept)
+	}
+	if !hasTag(kept[0], watchlist.Tag) || kept[0]["watchlist_rule"] != "cloudtrail" {
+		t.Errorf("expected StopLogging to be kept by the cloudtrail watchlist rule, got %v", kept[0])
+	}
+}
+
+func TestRootBypassesConsoleLoginFilter(t *testing.T) {
+	record := map[string]interface{}{
+		"eventID":         "1",
+		"eventSource":     "signin.amazonaws.com",
+		"eventName":       "ConsoleLogin",
+		"sourceIPAddress": "203.0.113.7",
+		"userIdentity":    map[string]interface{}{"type": "Root", "principalId": "123456789012"},
+	}
+	userIdentity := record["userIdentity"].(map[string]interface{})
+
+	if drop, _ := suppressed(record, userIdentity, "root", "ConsoleLogin", nil); !drop {
+		t.Fatal("expected ConsoleLogin to be suppressed by the filters")
+	}
+
+	// An IAM user's sign-in is still dropped
+	user := map[string]interface{}{
+		"eventID":      "2",
+		"eventSource":  "signin.amazonaws.com",
+		"eventName":    "ConsoleLogin",
+		"userIdentity": map[string]interface{}{"type": "IAMUser", "userName": "jane"},
+	}
+	kept := keptEvents(t, record, user)
+	if len(kept) != 1 || kept[0]["event_id"] != "1" {
+		t.Fatalf("expected only the root sign-in to be kept, got %v", kept)
+	}
+	if !hasTag(kept[0], detect.RootTag) || kept[0]["root_activity"] != detect.RootSignIn || kept[0]["user_name"] != "root" {
+		t.Errorf("expected a root sign-in, got %v", kept[0])
+	}
+	if kept[0]["severity"] != severity.Critical {
+		t.Errorf("expected root use to be critical, got %v", kept[0]["severity"])
+	}
+}
+
+func TestOverrideDisablesFilters(t *testing.T) {
+	record := map[string]interface{}{
+		"eventSource":  "ec2.amazonaws.com",
+		"eventName":    "RunInstances",
+		"readOnly":     false,
+		"userAgent":    "aws-cli/2.2.5 Python/3.8.8 Darwin/20.4.0 exe/x86_64 prompt/off command/ec2.run-instances",
+		"userIdentity": map[string]interface{}{"type": "IAMUser", "userName": "ci"},
+	}
+	userIdentity := record["userIdentity"].(map[string]interface{})
+
+	if drop, _ := suppressed(record, userIdentity, "ci", "RunInstances", nil); !drop {
+		t.Fatal("expected the CLI call to be suppressed globally")
+	}
+	ov := &override.Settings{DisableFilters: []string{classify.SignalUserAgent}}
+	if drop, signal := suppressed(record, userIdentity, "ci", "RunInstances", ov); drop {
+		t.Errorf("expected the override to keep the CLI call, dropped by %s", signal)
+	}
+}
+
+// withApp replaces the configuration loadApp returns for the rest of the
+// test.
+func withApp(t *testing.T, cfg *config.Config) *app {
+	a := &app{config: cfg, notifiers: notify.NewSet(cfg.Destinations, cfg.Accounts)}
+	appOnce = sync.Once{}
+	appOnce.Do(func() {})
+	appState, appErr = a, nil
+	t.Cleanup(func() {
+		appOnce = sync.Once{}
+		appState, appErr = nil, nil
+	})
+	return a
+}
+
+func TestFailedDeliveryLeavesObjectUnfinished(t *testing.T) {
+	var mu sync.Mutex
+	var up bool
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		if !up {
+			fmt.Fprint(w, "no_service")
+			return
+		}
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+
+	a := withApp(t, &config.Config{
+		Destinations: map[string]notify.Destination{"slack": {Type: "slack", URL: srv.URL}},
+		Routes:       route.Table{Default: []route.Target{{Destination: "slack"}}},
+	})
+	a.checkpoints = checkpoint.NewMemoryStore(checkpoint.DefaultTTL)
+	a.checkpointBatch = checkpoint.DefaultBatchSize
+
+	logFile := &CloudTrailFile{Records: []map[string]interface{}{{
+		"eventID":            "1",
+		"eventTime":          "2021-05-14T19:03:40Z",
+		"eventSource":        "cloudtrail.amazonaws.com",
+		"eventName":          "StopLogging",
+		"recipientAccountId": "123456789012",
+		"userAgent":          "aws-cli/2.2.5 Python/3.8.8 Darwin/20.4.0 exe/x86_64 prompt/off command/cloudtrail.stop-logging",
+		"userIdentity":       map[string]interface{}{"type": "IAMUser", "userName": "ci"},
+	}}}
+
+	progress, _ := checkpoint.Open(a.checkpoints, "trail", "AWSLogs/1.json.gz", "abc")
+	if err := filterRecords(logFile, handler.Record{}, progress); err == nil {
+		t.Fatal("expected the failed delivery to be returned")
+	}
+	progress, _ = checkpoint.Open(a.checkpoints, "trail", "AWSLogs/1.json.gz", "abc")
+	if progress.Done() || progress.Resume() != 0 {
+		t.Fatalf("expected the object to be left for a retry, got %+v", progress.State)
+	}
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	if err := filterRecords(logFile, handler.Record{}, progress); err != nil {
+		t.Fatal(err)
+	}
+	progress, _ = checkpoint.Open(a.checkpoints, "trail", "AWSLogs/1.json.gz", "abc")
+	if !progress.Done() {
+		t.Error("expected the retry to finish the object")
+	}
+}
+
+func TestFailedDeliveryWithoutCheckpointsIsLogged(t *testing.T) {
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(srv.Close)
+
+	withApp(t, &config.Config{
+		Destinations: map[string]notify.Destination{"slack": {Type: "slack", URL: srv.URL}},
+		Routes:       route.Table{Default: []route.Target{{Destination: "slack"}}},
+	})
+
+	logFile := &CloudTrailFile{Records: []map[string]interface{}{{
+		"eventID":            "1",
+		"eventTime":          "2021-05-14T19:03:40Z",
+		"eventSource":        "cloudtrail.amazonaws.com",
+		"eventName":          "StopLogging",
+		"recipientAccountId": "123456789012",
+		"userAgent":          "aws-cli/2.2.5 Python/3.8.8 Darwin/20.4.0 exe/x86_64 prompt/off command/cloudtrail.stop-logging",
+		"userIdentity":       map[string]interface{}{"type": "IAMUser", "userName": "ci"},
+	}}}
+
+	// Without a checkpoint or dedup store a retry would repeat every message
+	if err := filterRecords(logFile, handler.Record{}, nil); err != nil {
+		t.Fatalf("expected the failure to be logged, got %v", err)
+	}
+	if calls == 0 {
+		t.Error("expected the webhook to be called")
+	}
+}
+
+func TestExpiredDedupLeaseIsRetried(t *testing.T) {
+	var mu sync.Mutex
+	var calls int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		calls++
+		mu.Unlock()
+		fmt.Fprint(w, "ok")
+	}))
+	t.Cleanup(srv.Close)
+
+	a := withApp(t, &config.Config{
+		Destinations: map[string]notify.Destination{"slack": {Type: "slack", URL: srv.URL}},
+		Routes:       route.Table{Default: []route.Target{{Destination: "slack"}}},
+	})
+	a.dedup = dedup.NewMemoryStore(dedup.DefaultTTL, 50*time.Millisecond)
+
+	logFile := &CloudTrailFile{Records: []map[string]interface{}{{
+		"eventID":            "1",
+		"eventTime":          "2021-05-14T19:03:40Z",
+		"eventSource":        "cloudtrail.amazonaws.com",
+		"eventName":          "StopLogging",
+		"recipientAccountId": "123456789012",
+		"userAgent":          "aws-cli/2.2.5 Python/3.8.8 Darwin/20.4.0 exe/x86_64 prompt/off command/cloudtrail.stop-logging",
+		"userIdentity":       map[string]interface{}{"type": "IAMUser", "userName": "ci"},
+	}}}
+
+	// An invocation claimed the event and died before sending it
+	if ok, err := a.dedup.Claim(dedup.Key("1", "slack", "")); !ok {
+		t.Fatal(err)
+	}
+	if err := filterRecords(logFile, handler.Record{}, nil); err == nil {
+		t.Fatal("expected a leased event to be left for a retry")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	for i := 0; i < 2; i++ {
+		if err := filterRecords(logFile, handler.Record{}, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if calls != 1 {
+		t.Errorf("expected the event to be sent once after the lease expired, got %d", calls)
+	}
+}
@@ -0,0 +1,78 @@
+package account
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"strings"
+)
+
+// Account describes an AWS account events may originate from.
+type Account struct {
+	Name   string   `json:"name"`
+	Tags   []string `json:"tags"`
+	Emails []string `json:"emails"`
+
+	// OU is the account's Organizations OU path, e.g.
+	// "Root/Workloads/Sandbox".
+	OU string `json:"ou"`
+}
+
+// Directory maps account IDs to their metadata.
+type Directory map[string]Account
+
+// Tags returns the tags configured for an account.
+func (d Directory) Tags(id string) []string {
+	return d[id].Tags
+}
+
+// OU returns the OU path configured for an account.
+func (d Directory) OU(id string) string {
+	return d[id].OU
+}
+
+// InOU reports whether the account's OU path matches any of the glob
+// patterns, directly or through one of its parent OUs, so "Root/Sandbox"
+// also matches "Root/Sandbox/Team".
+func (d Directory) InOU(id string, patterns []string) bool {
+	ou := d[id].OU
+	if ou == "" {
+		return false
+	}
+	parts := strings.Split(ou, "/")
+	for i := len(parts); i > 0; i-- {
+		parent := strings.Join(parts[:i], "/")
+		for _, p := range patterns {
+			if ok, _ := path.Match(p, parent); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// HasTag reports whether the account carries tag.
+func (d Directory) HasTag(id, tag string) bool {
+	for _, t := range d[id].Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// Name resolves the display name for an account. SLACK_NAME_<account> keyed on
+// the identity's account wins, then the configured name, then SLACK_NAME and
+// finally the account ID itself.
+func (d Directory) Name(accountID, identityAccountID string) string {
+	if v := os.Getenv(fmt.Sprintf("SLACK_NAME_%s", identityAccountID)); v != "" {
+		return v
+	}
+	if a, ok := d[accountID]; ok && a.Name != "" {
+		return a.Name
+	}
+	if v := os.Getenv("SLACK_NAME"); v != "" {
+		return v
+	}
+	return accountID
+}
@@ -0,0 +1,111 @@
+package action
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/useragent"
+)
+
+// Action is a CloudTrail record that survived filtering and is ready to be
+// logged or delivered to a notifier.
+type Action struct {
+	EventID           string    `json:"event_id"`
+	EventTime         time.Time `json:"event_time"`
+	EventName         string    `json:"event_name"`
+	EventSource       string    `json:"event_source"`
+	AccountID         string    `json:"account_id"`
+	IdentityAccountID string    `json:"identity_account_id,omitempty"`
+	Region            string    `json:"region"`
+	UserName          string    `json:"user_name"`
+	Principal         string    `json:"principal"`
+	UserAgent         string    `json:"user_agent"`
+	ErrorCode         string    `json:"error_code,omitempty"`
+	SourceIP          string    `json:"source_ip,omitempty"`
+	S3URI             string    `json:"s3_uri"`
+
+	// Client is the category of tool the user agent belongs to, such as
+	// "console" or "terraform", and Via names it for people, e.g.
+	// "Terraform 1.7.5".
+	Client string `json:"client,omitempty"`
+	Via    string `json:"via,omitempty"`
+
+	// Severity is assigned by scoring rules and is empty when unscored.
+	Severity string `json:"severity,omitempty"`
+
+	// Tags label why an action was kept when it was not an ordinary console
+	// action, e.g. "watchlist".
+	Tags []string `json:"tags,omitempty"`
+
+	// Detail explains a detection in a few words, e.g. "5 failed sign-ins
+	// for alice from 203.0.113.7".
+	Detail string `json:"detail,omitempty"`
+
+	// Record is the raw CloudTrail record the action was built from.
+	Record map[string]interface{} `json:"-"`
+}
+
+// New builds an Action from a raw CloudTrail record. The user name and
+// account are resolved by the caller since they depend on filtering rules.
+func New(record map[string]interface{}, userName, accountID, s3URI string) *Action {
+	userIdentity, _ := record["userIdentity"].(map[string]interface{})
+
+	a := &Action{
+		EventID:     StringField(record, "eventID"),
+		EventName:   StringField(record, "eventName"),
+		EventSource: StringField(record, "eventSource"),
+		AccountID:   accountID,
+		Region:      StringField(record, "awsRegion"),
+		UserName:    userName,
+		Principal:   StringField(userIdentity, "principalId"),
+		UserAgent:   StringField(record, "userAgent"),
+		ErrorCode:   StringField(record, "errorCode"),
+		SourceIP:    StringField(record, "sourceIPAddress"),
+		S3URI:       s3URI,
+		Record:      record,
+	}
+
+	agent := useragent.Parse(a.UserAgent)
+	a.Client, a.Via = agent.Category, agent.String()
+
+	if v, ok := userIdentity["accountId"]; ok {
+		a.IdentityAccountID = fmt.Sprintf("%s", v)
+	}
+
+	if t, err := time.Parse(time.RFC3339, StringField(record, "eventTime")); err == nil {
+		a.EventTime = t
+	}
+
+	return a
+}
+
+// HasTag reports whether the action carries tag.
+func (a *Action) HasTag(tag string) bool {
+	for _, t := range a.Tags {
+		if t == tag {
+			return true
+		}
+	}
+	return false
+}
+
+// ConsoleURL links to the event in the CloudTrail console.
+func (a *Action) ConsoleURL() string {
+	return fmt.Sprintf("https://console.aws.amazon.com/cloudtrail/home?region=%s#/events?EventId=%s", a.Region, a.EventID)
+}
+
+// EventTimeString returns the event time as CloudTrail formats it.
+func (a *Action) EventTimeString() string {
+	if a.EventTime.IsZero() {
+		return ""
+	}
+	return a.EventTime.UTC().Format(time.RFC3339)
+}
+
+// StringField returns m[key] if it is a string and the empty string otherwise.
+func StringField(m map[string]interface{}, key string) string {
+	if v, ok := m[key].(string); ok {
+		return v
+	}
+	return ""
+}
@@ -0,0 +1,273 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+)
+
+// Formats the archive can be written in.
+const (
+	FormatJSONLines = "jsonl"
+	FormatParquet   = "parquet"
+)
+
+// Config is the archive section of the configuration file.
+type Config struct {
+	Bucket         string `json:"bucket"`
+	Prefix         string `json:"prefix"`
+	Format         string `json:"format"`
+	IncludeDropped bool   `json:"include_dropped"`
+	Region         string `json:"region"`
+	Endpoint       string `json:"endpoint"`
+}
+
+// Archiver writes the records of each CloudTrail file to S3, partitioned by
+// account, region and date so the archive can be queried with Athena.
+type Archiver struct {
+	Config
+	Client s3iface.S3API
+}
+
+// Row is a single archived record. Kept and dropped records share a schema so
+// one table definition covers both prefixes.
+type Row struct {
+	EventID     string    `json:"event_id"`
+	EventTime   time.Time `json:"event_time"`
+	EventName   string    `json:"event_name"`
+	EventSource string    `json:"event_source"`
+	AccountID   string    `json:"account_id"`
+	Region      string    `json:"region"`
+	UserName    string    `json:"user_name"`
+	Principal   string    `json:"principal"`
+	UserAgent   string    `json:"user_agent"`
+	ErrorCode   string    `json:"error_code"`
+	SourceIP    string    `json:"source_ip"`
+	Severity    string    `json:"severity"`
+	S3URI       string    `json:"s3_uri"`
+	Record      string    `json:"record"`
+}
+
+// New returns an Archiver for cfg.
+func New(cfg Config) (*Archiver, error) {
+	if cfg.Bucket == "" {
+		return nil, fmt.Errorf("archive requires a bucket")
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = FormatJSONLines
+	case FormatJSONLines, FormatParquet:
+	default:
+		return nil, fmt.Errorf("unknown archive format %q", cfg.Format)
+	}
+
+	awsCfg := aws.NewConfig()
+	if cfg.Region != "" {
+		awsCfg = awsCfg.WithRegion(cfg.Region)
+	}
+	if cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.Endpoint).WithS3ForcePathStyle(true)
+	}
+
+	return &Archiver{
+		Config: cfg,
+		Client: s3.New(session.Must(session.NewSession()), awsCfg),
+	}, nil
+}
+
+// Write archives the kept actions, and the dropped records when enabled, read
+// from the CloudTrail object at s3URI. Each partition receives one object
+// named after the source file, so reprocessing a file overwrites its archive.
+func (ar *Archiver) Write(s3URI string, kept []*action.Action, dropped []map[string]interface{}) error {
+	var rows []Row
+	for _, a := range kept {
+		rows = append(rows, RowFromAction(a))
+	}
+	if err := ar.writePartitions("kept", s3URI, rows); err != nil {
+		return err
+	}
+
+	if !ar.IncludeDropped {
+		return nil
+	}
+
+	rows = nil
+	for _, record := range dropped {
+		rows = append(rows, RowFromRecord(record, s3URI))
+	}
+	return ar.writePartitions("dropped", s3URI, rows)
+}
+
+func (ar *Archiver) writePartitions(status, s3URI string, rows []Row) error {
+	var order []string
+	partitions := make(map[string][]Row)
+	for _, r := range rows {
+		key := ar.Key(status, s3URI, r)
+		if _, ok := partitions[key]; !ok {
+			order = append(order, key)
+		}
+		partitions[key] = append(partitions[key], r)
+	}
+
+	for _, key := range order {
+		body, contentType, err := ar.encode(partitions[key])
+		if err != nil {
+			return err
+		}
+
+		_, err = ar.Client.PutObject(&s3.PutObjectInput{
+			Bucket:      aws.String(ar.Bucket),
+			Key:         aws.String(key),
+			Body:        bytes.NewReader(body),
+			ContentType: aws.String(contentType),
+		})
+		if err != nil {
+			return fmt.Errorf("archiving s3://%s/%s: %v", ar.Bucket, key, err)
+		}
+	}
+	return nil
+}
+
+// Key returns the object key a row is archived under.
+func (ar *Archiver) Key(status, s3URI string, r Row) string {
+	date := "unknown"
+	if !r.EventTime.IsZero() {
+		date = r.EventTime.UTC().Format("2006-01-02")
+	}
+
+	name := path.Base(s3URI)
+	name = strings.TrimSuffix(name, ".gz")
+	name = strings.TrimSuffix(name, ".json")
+	if ar.Format == FormatParquet {
+		name += ".parquet"
+	} else {
+		name += ".jsonl.gz"
+	}
+
+	return path.Join(
+		ar.Prefix,
+		status,
+		"account="+partitionValue(r.AccountID),
+		"region="+partitionValue(r.Region),
+		"date="+date,
+		name,
+	)
+}
+
+func (ar *Archiver) encode(rows []Row) ([]byte, string, error) {
+	var buf bytes.Buffer
+
+	if ar.Format == FormatParquet {
+		err := writeParquet(&buf, parquetColumns(rows), len(rows))
+		return buf.Bytes(), "application/vnd.apache.parquet", err
+	}
+
+	gz := gzip.NewWriter(&buf)
+	enc := json.NewEncoder(gz)
+	for _, r := range rows {
+		if err := enc.Encode(r); err != nil {
+			return nil, "", err
+		}
+	}
+	if err := gz.Close(); err != nil {
+		return nil, "", err
+	}
+	// Not Content-Encoding: gzip, which HTTP clients undo on download and
+	// would hand readers plain JSON under a .gz name
+	return buf.Bytes(), "application/gzip", nil
+}
+
+func parquetColumns(rows []Row) []*column {
+	eventTime := &column{name: "event_time", millis: true}
+	columns := []*column{{name: "event_id"}, eventTime}
+	for _, name := range []string{
+		"event_name", "event_source", "account_id", "region", "user_name", "principal",
+		"user_agent", "error_code", "source_ip", "severity", "s3_uri", "record",
+	} {
+		columns = append(columns, &column{name: name})
+	}
+
+	for _, r := range rows {
+		var millis int64
+		if !r.EventTime.IsZero() {
+			millis = r.EventTime.UnixNano() / 1e6
+		}
+		eventTime.int64s = append(eventTime.int64s, millis)
+
+		values := []string{
+			r.EventID, r.EventName, r.EventSource, r.AccountID, r.Region, r.UserName, r.Principal,
+			r.UserAgent, r.ErrorCode, r.SourceIP, r.Severity, r.S3URI, r.Record,
+		}
+		columns[0].strings = append(columns[0].strings, values[0])
+		for i, v := range values[1:] {
+			columns[i+2].strings = append(columns[i+2].strings, v)
+		}
+	}
+	return columns
+}
+
+// RowFromAction converts a kept action to an archive row.
+func RowFromAction(a *action.Action) Row {
+	record, _ := json.Marshal(a.Record)
+	return Row{
+		EventID:     a.EventID,
+		EventTime:   a.EventTime,
+		EventName:   a.EventName,
+		EventSource: a.EventSource,
+		AccountID:   a.AccountID,
+		Region:      a.Region,
+		UserName:    a.UserName,
+		Principal:   a.Principal,
+		UserAgent:   a.UserAgent,
+		ErrorCode:   a.ErrorCode,
+		SourceIP:    a.SourceIP,
+		Severity:    a.Severity,
+		S3URI:       a.S3URI,
+		Record:      string(record),
+	}
+}
+
+// RowFromRecord converts a dropped CloudTrail record to an archive row.
+func RowFromRecord(record map[string]interface{}, s3URI string) Row {
+	userIdentity, _ := record["userIdentity"].(map[string]interface{})
+	raw, _ := json.Marshal(record)
+
+	r := Row{
+		EventID:     action.StringField(record, "eventID"),
+		EventName:   action.StringField(record, "eventName"),
+		EventSource: action.StringField(record, "eventSource"),
+		AccountID:   action.StringField(record, "recipientAccountId"),
+		Region:      action.StringField(record, "awsRegion"),
+		UserName:    action.StringField(userIdentity, "userName"),
+		Principal:   action.StringField(userIdentity, "principalId"),
+		UserAgent:   action.StringField(record, "userAgent"),
+		ErrorCode:   action.StringField(record, "errorCode"),
+		SourceIP:    action.StringField(record, "sourceIPAddress"),
+		S3URI:       s3URI,
+		Record:      string(raw),
+	}
+	if r.AccountID == "" {
+		r.AccountID = action.StringField(userIdentity, "accountId")
+	}
+	if t, err := time.Parse(time.RFC3339, action.StringField(record, "eventTime")); err == nil {
+		r.EventTime = t
+	}
+	return r
+}
+
+func partitionValue(v string) string {
+	if v == "" {
+		return "unknown"
+	}
+	return v
+}
@@ -0,0 +1,154 @@
+package archive
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+)
+
+type fakeS3 struct {
+	mu           sync.Mutex
+	objects      map[string][]byte
+	contentTypes map[string]string
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := ioutil.ReadAll(r.Body)
+	f.mu.Lock()
+	f.objects[r.URL.Path] = body
+	f.contentTypes[r.URL.Path] = r.Header.Get("Content-Type")
+	f.mu.Unlock()
+	w.Header().Set("ETag", `"etag"`)
+}
+
+func (f *fakeS3) keys() []string {
+	var keys []string
+	for k := range f.objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func testArchiver(t *testing.T, format string) (*Archiver, *fakeS3) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+
+	fake := &fakeS3{objects: make(map[string][]byte), contentTypes: make(map[string]string)}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	ar, err := New(Config{
+		Bucket:         "archive",
+		Prefix:         "console-actions",
+		Format:         format,
+		IncludeDropped: true,
+		Region:         "us-east-1",
+		Endpoint:       srv.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return ar, fake
+}
+
+const testSource = "s3://trail/AWSLogs/111111111111/CloudTrail/us-east-1/2021/05/14/111111111111_CloudTrail_us-east-1_20210514T1905Z_abc.json.gz"
+
+func testRecords() ([]*action.Action, []map[string]interface{}) {
+	at := time.Date(2021, 5, 14, 19, 3, 40, 0, time.UTC)
+	kept := []*action.Action{
+		{EventID: "1", EventTime: at, AccountID: "111111111111", Region: "us-east-1", EventName: "CreateTags"},
+		{EventID: "2", EventTime: at, AccountID: "222222222222", Region: "us-east-1", EventName: "PutUserPolicy"},
+	}
+	dropped := []map[string]interface{}{
+		{"eventID": "3", "eventTime": "2021-05-14T19:03:41Z", "recipientAccountId": "111111111111", "awsRegion": "us-east-1", "eventName": "DescribeInstances"},
+	}
+	return kept, dropped
+}
+
+func TestWriteJSONLines(t *testing.T) {
+	ar, fake := testArchiver(t, "")
+	kept, dropped := testRecords()
+
+	if err := ar.Write(testSource, kept, dropped); err != nil {
+		t.Fatal(err)
+	}
+
+	want := []string{
+		"/archive/console-actions/dropped/account=111111111111/region=us-east-1/date=2021-05-14/111111111111_CloudTrail_us-east-1_20210514T1905Z_abc.jsonl.gz",
+		"/archive/console-actions/kept/account=111111111111/region=us-east-1/date=2021-05-14/111111111111_CloudTrail_us-east-1_20210514T1905Z_abc.jsonl.gz",
+		"/archive/console-actions/kept/account=222222222222/region=us-east-1/date=2021-05-14/111111111111_CloudTrail_us-east-1_20210514T1905Z_abc.jsonl.gz",
+	}
+	if got := fake.keys(); strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Fatalf("unexpected keys:\n%s", strings.Join(got, "\n"))
+	}
+	if got := fake.contentTypes[want[0]]; got != "application/gzip" {
+		t.Errorf("unexpected content type %q", got)
+	}
+
+	gz, err := gzip.NewReader(bytes.NewReader(fake.objects[want[0]]))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var row Row
+	if err := json.NewDecoder(gz).Decode(&row); err != nil {
+		t.Fatal(err)
+	}
+	if row.EventName != "DescribeInstances" || !strings.Contains(row.Record, `"eventID":"3"`) {
+		t.Fatalf("unexpected row %+v", row)
+	}
+}
+
+func TestWriteParquet(t *testing.T) {
+	ar, fake := testArchiver(t, FormatParquet)
+	kept, _ := testRecords()
+	later := kept[0].EventTime.Add(time.Second)
+	kept = append(kept, &action.Action{EventID: "4", EventTime: later, AccountID: "111111111111", Region: "us-east-1", EventName: "RunInstances"})
+
+	if err := ar.Write(testSource, kept, nil); err != nil {
+		t.Fatal(err)
+	}
+
+	key := "/archive/console-actions/kept/account=111111111111/region=us-east-1/date=2021-05-14/111111111111_CloudTrail_us-east-1_20210514T1905Z_abc.parquet"
+	body, ok := fake.objects[key]
+	if !ok {
+		t.Fatalf("expected a parquet object at %s, got %v", key, fake.keys())
+	}
+	f := readParquet(t, body)
+
+	if f.meta[1] != int64(1) || f.meta[3] != int64(2) {
+		t.Errorf("unexpected version %v or num_rows %v", f.meta[1], f.meta[3])
+	}
+	want := []string{
+		"event_id", "event_time", "event_name", "event_source", "account_id", "region", "user_name",
+		"principal", "user_agent", "error_code", "source_ip", "severity", "s3_uri", "record",
+	}
+	if strings.Join(f.names, ",") != strings.Join(want, ",") {
+		t.Errorf("unexpected columns %v", f.names)
+	}
+	schema := f.meta[2].([]interface{})
+	if eventTime := schema[2].(map[int16]interface{}); eventTime[1] != int64(parquetInt64) || eventTime[6] != int64(parquetTimestampMillis) {
+		t.Errorf("unexpected event_time schema %v", eventTime)
+	}
+	if eventName := schema[3].(map[int16]interface{}); eventName[1] != int64(parquetByteArray) || eventName[6] != int64(parquetUTF8) {
+		t.Errorf("unexpected event_name schema %v", eventName)
+	}
+
+	if got := f.values["event_name"]; len(got) != 2 || got[0] != "CreateTags" || got[1] != "RunInstances" {
+		t.Errorf("unexpected event names %v", got)
+	}
+	millis := kept[0].EventTime.UnixNano() / 1e6
+	if got := f.values["event_time"]; len(got) != 2 || got[0] != millis || got[1] != millis+1000 {
+		t.Errorf("unexpected event times %v", got)
+	}
+}
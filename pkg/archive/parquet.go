@@ -0,0 +1,253 @@
+package archive
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+)
+
+// This is a deliberately small Parquet writer covering what the archive
+// needs: a single row group of REQUIRED, PLAIN encoded, uncompressed columns
+// holding UTF8 strings or millisecond timestamps.
+
+const parquetMagic = "PAR1"
+
+// Parquet physical and converted types used by the archive schema.
+const (
+	parquetInt64     = 2
+	parquetByteArray = 6
+
+	parquetUTF8            = 0
+	parquetTimestampMillis = 9
+
+	parquetRequired     = 0
+	parquetPlain        = 0
+	parquetRLE          = 3
+	parquetDataPage     = 0
+	parquetUncompressed = 0
+)
+
+// column is a single Parquet column and its values.
+type column struct {
+	name    string
+	strings []string
+	int64s  []int64
+	millis  bool
+}
+
+func (c *column) physicalType() int32 {
+	if c.millis {
+		return parquetInt64
+	}
+	return parquetByteArray
+}
+
+func (c *column) convertedType() int32 {
+	if c.millis {
+		return parquetTimestampMillis
+	}
+	return parquetUTF8
+}
+
+func (c *column) plain() []byte {
+	var buf bytes.Buffer
+	var b [8]byte
+	if c.millis {
+		for _, v := range c.int64s {
+			binary.LittleEndian.PutUint64(b[:], uint64(v))
+			buf.Write(b[:])
+		}
+		return buf.Bytes()
+	}
+	for _, v := range c.strings {
+		binary.LittleEndian.PutUint32(b[:4], uint32(len(v)))
+		buf.Write(b[:4])
+		buf.WriteString(v)
+	}
+	return buf.Bytes()
+}
+
+// writeParquet writes the columns as a Parquet file with one row group. The
+// file is assembled in memory, where writes cannot fail, and written to w
+// once.
+func writeParquet(w io.Writer, columns []*column, rows int) error {
+	var file bytes.Buffer
+	file.WriteString(parquetMagic)
+
+	type chunk struct {
+		offset int64
+		size   int64
+	}
+	var chunks []chunk
+
+	for _, c := range columns {
+		data := c.plain()
+
+		var header thriftWriter
+		header.fieldI32(1, parquetDataPage)
+		header.fieldI32(2, int32(len(data)))
+		header.fieldI32(3, int32(len(data)))
+		header.fieldStructBegin(5)
+		header.fieldI32(1, int32(rows))
+		header.fieldI32(2, parquetPlain)
+		header.fieldI32(3, parquetRLE)
+		header.fieldI32(4, parquetRLE)
+		header.structEnd()
+		header.structEnd()
+
+		offset := int64(file.Len())
+		file.Write(header.Bytes())
+		file.Write(data)
+		chunks = append(chunks, chunk{offset: offset, size: int64(file.Len()) - offset})
+	}
+
+	var total int64
+	for _, ch := range chunks {
+		total += ch.size
+	}
+
+	var meta thriftWriter
+	meta.fieldI32(1, 1)
+
+	// Schema is a root element followed by one element per column
+	meta.fieldListBegin(2, thriftStruct, len(columns)+1)
+	meta.structBegin()
+	meta.fieldString(4, "schema")
+	meta.fieldI32(5, int32(len(columns)))
+	meta.structEnd()
+	for _, c := range columns {
+		meta.structBegin()
+		meta.fieldI32(1, c.physicalType())
+		meta.fieldI32(3, parquetRequired)
+		meta.fieldString(4, c.name)
+		meta.fieldI32(6, c.convertedType())
+		meta.structEnd()
+	}
+
+	meta.fieldI64(3, int64(rows))
+
+	meta.fieldListBegin(4, thriftStruct, 1)
+	meta.structBegin()
+	meta.fieldListBegin(1, thriftStruct, len(columns))
+	for i, c := range columns {
+		meta.structBegin()
+		meta.fieldI64(2, chunks[i].offset)
+		meta.fieldStructBegin(3)
+		meta.fieldI32(1, c.physicalType())
+		meta.fieldListBegin(2, thriftI32, 1)
+		meta.writeVarint(zigzag32(parquetPlain))
+		meta.fieldListBegin(3, thriftBinary, 1)
+		meta.writeString(c.name)
+		meta.fieldI32(4, parquetUncompressed)
+		meta.fieldI64(5, int64(rows))
+		meta.fieldI64(6, chunks[i].size)
+		meta.fieldI64(7, chunks[i].size)
+		meta.fieldI64(9, chunks[i].offset)
+		meta.structEnd()
+		meta.structEnd()
+	}
+	meta.fieldI64(2, total)
+	meta.fieldI64(3, int64(rows))
+	meta.structEnd()
+
+	meta.fieldString(6, "cloudtrail-console-actions")
+	meta.structEnd()
+
+	var size [4]byte
+	binary.LittleEndian.PutUint32(size[:], uint32(meta.Len()))
+	file.Write(meta.Bytes())
+	file.Write(size[:])
+	file.WriteString(parquetMagic)
+
+	_, err := w.Write(file.Bytes())
+	return err
+}
+
+// Thrift compact protocol type IDs.
+const (
+	thriftI32    = 5
+	thriftI64    = 6
+	thriftBinary = 8
+	thriftList   = 9
+	thriftStruct = 12
+)
+
+// thriftWriter encodes the subset of the Thrift compact protocol needed for
+// Parquet page headers and file metadata.
+type thriftWriter struct {
+	bytes.Buffer
+	last  int16
+	stack []int16
+}
+
+func (t *thriftWriter) fieldHeader(id int16, typ byte) {
+	if delta := id - t.last; delta > 0 && delta <= 15 {
+		t.WriteByte(byte(delta)<<4 | typ)
+	} else {
+		t.WriteByte(typ)
+		t.writeVarint(zigzag32(int32(id)))
+	}
+	t.last = id
+}
+
+func (t *thriftWriter) fieldI32(id int16, v int32) {
+	t.fieldHeader(id, thriftI32)
+	t.writeVarint(zigzag32(v))
+}
+
+func (t *thriftWriter) fieldI64(id int16, v int64) {
+	t.fieldHeader(id, thriftI64)
+	t.writeVarint(zigzag64(v))
+}
+
+func (t *thriftWriter) fieldString(id int16, v string) {
+	t.fieldHeader(id, thriftBinary)
+	t.writeString(v)
+}
+
+func (t *thriftWriter) fieldStructBegin(id int16) {
+	t.fieldHeader(id, thriftStruct)
+	t.structBegin()
+}
+
+func (t *thriftWriter) fieldListBegin(id int16, elem byte, size int) {
+	t.fieldHeader(id, thriftList)
+	if size < 15 {
+		t.WriteByte(byte(size)<<4 | elem)
+		return
+	}
+	t.WriteByte(0xf0 | elem)
+	t.writeVarint(uint64(size))
+}
+
+func (t *thriftWriter) structBegin() {
+	t.stack = append(t.stack, t.last)
+	t.last = 0
+}
+
+func (t *thriftWriter) structEnd() {
+	t.WriteByte(0)
+	if n := len(t.stack); n > 0 {
+		t.last = t.stack[n-1]
+		t.stack = t.stack[:n-1]
+	}
+}
+
+func (t *thriftWriter) writeString(v string) {
+	t.writeVarint(uint64(len(v)))
+	t.WriteString(v)
+}
+
+func (t *thriftWriter) writeVarint(v uint64) {
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	t.Write(buf[:n])
+}
+
+func zigzag32(v int32) uint64 {
+	return uint64(uint32((v << 1) ^ (v >> 31)))
+}
+
+func zigzag64(v int64) uint64 {
+	return uint64((v << 1) ^ (v >> 63))
+}
@@ -0,0 +1,177 @@
+package archive
+
+import (
+	"encoding/binary"
+	"fmt"
+	"testing"
+)
+
+// thriftReader decodes Thrift compact protocol structs into maps of field ID
+// to value, so tests can check what the writer produced without a Parquet
+// library.
+type thriftReader struct {
+	data []byte
+	pos  int
+}
+
+func (r *thriftReader) next() byte {
+	if r.pos >= len(r.data) {
+		panic("thrift: unexpected end of data")
+	}
+	b := r.data[r.pos]
+	r.pos++
+	return b
+}
+
+func (r *thriftReader) varint() uint64 {
+	v, n := binary.Uvarint(r.data[r.pos:])
+	if n <= 0 {
+		panic("thrift: invalid varint")
+	}
+	r.pos += n
+	return v
+}
+
+func (r *thriftReader) value(typ byte) interface{} {
+	switch typ {
+	case 1, 2:
+		return typ == 1
+	case 3:
+		return int64(int8(r.next()))
+	case 4, 5, 6:
+		v := r.varint()
+		return int64(v>>1) ^ -int64(v&1)
+	case 8:
+		n := int(r.varint())
+		s := string(r.data[r.pos : r.pos+n])
+		r.pos += n
+		return s
+	case 9:
+		b := r.next()
+		size, elem := int(b>>4), b&0x0f
+		if size == 15 {
+			size = int(r.varint())
+		}
+		list := make([]interface{}, size)
+		for i := range list {
+			list[i] = r.value(elem)
+		}
+		return list
+	case 12:
+		return r.readStruct()
+	}
+	panic(fmt.Sprintf("thrift: unsupported type %d", typ))
+}
+
+func (r *thriftReader) readStruct() map[int16]interface{} {
+	fields := make(map[int16]interface{})
+	var last int16
+	for {
+		b := r.next()
+		if b == 0 {
+			return fields
+		}
+		id := last + int16(b>>4)
+		if b>>4 == 0 {
+			v := r.varint()
+			id = int16(int64(v>>1) ^ -int64(v&1))
+		}
+		fields[id] = r.value(b & 0x0f)
+		last = id
+	}
+}
+
+// parquetFile is what readParquet found in a file: its metadata and every
+// column's values, as strings or int64s.
+type parquetFile struct {
+	meta   map[int16]interface{}
+	names  []string
+	values map[string][]interface{}
+}
+
+// readParquet checks the layout of a file written by writeParquet, failing t
+// when a footer, page header or offset is off, and reads its values back.
+func readParquet(t *testing.T, body []byte) *parquetFile {
+	t.Helper()
+	if len(body) < 12 || string(body[:4]) != parquetMagic || string(body[len(body)-4:]) != parquetMagic {
+		t.Fatal("missing PAR1 magic")
+	}
+	metaLen := int(binary.LittleEndian.Uint32(body[len(body)-8:]))
+	metaStart := len(body) - 8 - metaLen
+	if metaStart < 4 {
+		t.Fatalf("footer length %d overruns the file", metaLen)
+	}
+	r := &thriftReader{data: body[metaStart : len(body)-8]}
+	meta := r.readStruct()
+	if r.pos != metaLen {
+		t.Fatalf("footer is %d bytes, metadata decoded from %d", metaLen, r.pos)
+	}
+
+	rows := meta[3].(int64)
+	schema := meta[2].([]interface{})
+	root := schema[0].(map[int16]interface{})
+	if root[4] != "schema" || root[5] != int64(len(schema)-1) {
+		t.Fatalf("unexpected schema root %v", root)
+	}
+
+	rowGroups := meta[4].([]interface{})
+	if len(rowGroups) != 1 {
+		t.Fatalf("expected one row group, got %d", len(rowGroups))
+	}
+	group := rowGroups[0].(map[int16]interface{})
+	chunks := group[1].([]interface{})
+	if group[3] != rows || len(chunks) != len(schema)-1 {
+		t.Fatalf("unexpected row group %v", group)
+	}
+
+	f := &parquetFile{meta: meta, values: make(map[string][]interface{})}
+	next, total := int64(4), int64(0)
+	for i, c := range chunks {
+		element := schema[i+1].(map[int16]interface{})
+		name := element[4].(string)
+		f.names = append(f.names, name)
+
+		chunk := c.(map[int16]interface{})
+		colMeta := chunk[3].(map[int16]interface{})
+		offset := colMeta[9].(int64)
+		if chunk[2] != offset || offset != next {
+			t.Fatalf("%s: chunk at %v, data page at %d, expected %d", name, chunk[2], offset, next)
+		}
+		if colMeta[1] != element[1] || colMeta[5] != rows || colMeta[3].([]interface{})[0] != name {
+			t.Fatalf("%s: column metadata %v does not match schema %v", name, colMeta, element)
+		}
+
+		page := &thriftReader{data: body[offset:metaStart]}
+		header := page.readStruct()
+		dataHeader := header[5].(map[int16]interface{})
+		if header[1] != int64(parquetDataPage) || header[2] != header[3] || dataHeader[1] != rows {
+			t.Fatalf("%s: unexpected page header %v", name, header)
+		}
+		size := int64(page.pos) + header[3].(int64)
+		if colMeta[7] != size || colMeta[6] != size {
+			t.Fatalf("%s: chunk size %v, page is %d bytes", name, colMeta[7], size)
+		}
+
+		data := body[offset+int64(page.pos) : offset+size]
+		for n := int64(0); n < rows; n++ {
+			switch element[1] {
+			case int64(parquetInt64):
+				f.values[name] = append(f.values[name], int64(binary.LittleEndian.Uint64(data)))
+				data = data[8:]
+			case int64(parquetByteArray):
+				l := binary.LittleEndian.Uint32(data)
+				f.values[name] = append(f.values[name], string(data[4:4+l]))
+				data = data[4+l:]
+			}
+		}
+		if len(data) != 0 {
+			t.Fatalf("%s: %d bytes left after %d values", name, len(data), rows)
+		}
+		next += size
+		total += size
+	}
+	if next != int64(metaStart) || group[2] != total {
+		t.Fatalf("column chunks end at %d, total %v, metadata starts at %d", next, group[2], metaStart)
+	}
+	return f
+}
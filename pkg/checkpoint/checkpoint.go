@@ -0,0 +1,161 @@
+package checkpoint
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// Defaults for the checkpoint section.
+const (
+	DefaultTTL       = 30 * 24 * time.Hour
+	DefaultBatchSize = 50
+)
+
+// Config is the checkpoint section of the configuration file. URL selects the
+// backend: dynamodb://table, memory:// or a local file given as file:///path
+// or a plain path. BatchSize is how many kept records are notified between
+// checkpoints.
+type Config struct {
+	URL       string `json:"url"`
+	TTL       string `json:"ttl"`
+	BatchSize int    `json:"batch_size"`
+	Region    string `json:"region"`
+	Endpoint  string `json:"endpoint"`
+}
+
+// State is the progress through one version of a CloudTrail object.
+type State struct {
+	Bucket     string    `json:"bucket"`
+	Key        string    `json:"key"`
+	ETag       string    `json:"etag"`
+	NextRecord int       `json:"next_record"`
+	Done       bool      `json:"done"`
+	Updated    time.Time `json:"updated"`
+}
+
+// Store persists object progress.
+type Store interface {
+	// Get returns the state saved under id, or nil when there is none.
+	Get(id string) (*State, error)
+	Put(id string, s *State) error
+}
+
+// ID identifies a version of an object.
+func ID(bucket, key, etag string) string {
+	return bucket + "/" + key + "@" + strings.Trim(etag, `"`)
+}
+
+// New returns the Store cfg.URL points at.
+func New(cfg Config) (Store, error) {
+	ttl := DefaultTTL
+	if cfg.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(cfg.TTL); err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid checkpoint ttl %q", cfg.TTL)
+		}
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "dynamodb":
+		if u.Host == "" {
+			return nil, fmt.Errorf("checkpoint url requires a table, e.g. dynamodb://console-actions-checkpoints")
+		}
+		awsCfg := aws.NewConfig()
+		if cfg.Region != "" {
+			awsCfg = awsCfg.WithRegion(cfg.Region)
+		}
+		if cfg.Endpoint != "" {
+			awsCfg = awsCfg.WithEndpoint(cfg.Endpoint)
+		}
+		return &DynamoDBStore{
+			Table:  u.Host,
+			TTL:    ttl,
+			Client: dynamodb.New(session.Must(session.NewSession()), awsCfg),
+		}, nil
+	case "memory":
+		return NewMemoryStore(ttl), nil
+	case "file":
+		return &FileStore{Path: u.Path, TTL: ttl}, nil
+	case "":
+		if strings.TrimSpace(cfg.URL) == "" {
+			return nil, fmt.Errorf("checkpoint requires a url")
+		}
+		return &FileStore{Path: cfg.URL, TTL: ttl}, nil
+	}
+	return nil, fmt.Errorf("unsupported checkpoint url %q", cfg.URL)
+}
+
+// Progress tracks one object through a Store. A nil Progress records nothing
+// and resumes from the first record.
+type Progress struct {
+	ID    string
+	State State
+
+	store Store
+}
+
+// Open loads the progress of an object version, starting fresh when the store
+// has none.
+func Open(store Store, bucket, key, etag string) (*Progress, error) {
+	p := &Progress{
+		ID:    ID(bucket, key, etag),
+		State: State{Bucket: bucket, Key: key, ETag: strings.Trim(etag, `"`)},
+		store: store,
+	}
+
+	s, err := store.Get(p.ID)
+	if err != nil {
+		return nil, err
+	}
+	if s != nil {
+		p.State = *s
+	}
+	return p, nil
+}
+
+// Done reports whether the object was fully processed.
+func (p *Progress) Done() bool {
+	return p != nil && p.State.Done
+}
+
+// Resume returns the index of the first record not yet notified.
+func (p *Progress) Resume() int {
+	if p == nil {
+		return 0
+	}
+	return p.State.NextRecord
+}
+
+// Advance records that every record before next was notified.
+func (p *Progress) Advance(next int) error {
+	if p == nil {
+		return nil
+	}
+	p.State.NextRecord = next
+	return p.save()
+}
+
+// Complete records that the object was fully processed.
+func (p *Progress) Complete() error {
+	if p == nil {
+		return nil
+	}
+	p.State.Done = true
+	return p.save()
+}
+
+func (p *Progress) save() error {
+	p.State.Updated = time.Now().UTC()
+	return p.store.Put(p.ID, &p.State)
+}
@@ -0,0 +1,117 @@
+package checkpoint
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (f *fakeDynamoDB) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[aws.StringValue(in.Key["id"].S)]}, nil
+}
+
+func (f *fakeDynamoDB) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	f.items[aws.StringValue(in.Item["id"].S)] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func TestProgress(t *testing.T) {
+	file, err := New(Config{URL: "file://" + filepath.Join(t.TempDir(), "checkpoints.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynamo := &DynamoDBStore{Table: "checkpoints", TTL: DefaultTTL, Client: &fakeDynamoDB{items: make(map[string]map[string]*dynamodb.AttributeValue)}}
+
+	for name, store := range map[string]Store{"file": file, "memory": NewMemoryStore(DefaultTTL), "dynamodb": dynamo} {
+		t.Run(name, func(t *testing.T) {
+			p, err := Open(store, "trail", "AWSLogs/1.json.gz", `"abc"`)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.ID != "trail/AWSLogs/1.json.gz@abc" || p.Done() || p.Resume() != 0 {
+				t.Fatalf("expected fresh progress, got %+v", p)
+			}
+
+			// A run that dies after notifying the first 40 records
+			if err := p.Advance(40); err != nil {
+				t.Fatal(err)
+			}
+
+			p, _ = Open(store, "trail", "AWSLogs/1.json.gz", "abc")
+			if p.Done() || p.Resume() != 40 {
+				t.Fatalf("expected to resume at 40, got %+v", p.State)
+			}
+			if err := p.Complete(); err != nil {
+				t.Fatal(err)
+			}
+
+			p, _ = Open(store, "trail", "AWSLogs/1.json.gz", "abc")
+			if !p.Done() {
+				t.Error("expected the object to be done")
+			}
+
+			// A new version of the object starts over
+			p, _ = Open(store, "trail", "AWSLogs/1.json.gz", "def")
+			if p.Done() || p.Resume() != 0 {
+				t.Errorf("expected a new ETag to start fresh, got %+v", p.State)
+			}
+		})
+	}
+}
+
+func TestPrune(t *testing.T) {
+	file := &FileStore{Path: filepath.Join(t.TempDir(), "checkpoints.json"), TTL: time.Hour}
+	memory := NewMemoryStore(time.Hour)
+	now := time.Now()
+	memory.now = func() time.Time { return now }
+
+	for name, store := range map[string]Store{"file": file, "memory": memory} {
+		t.Run(name, func(t *testing.T) {
+			store.Put("old", &State{Done: true, Updated: now.Add(-2 * time.Hour)})
+			store.Put("new", &State{Done: true, Updated: now})
+
+			if s, _ := store.Get("old"); s != nil {
+				t.Errorf("expected the expired state to be pruned, got %+v", s)
+			}
+			if s, _ := store.Get("new"); s == nil {
+				t.Error("expected the recent state to be kept")
+			}
+		})
+	}
+
+	data, _ := ioutil.ReadFile(file.Path)
+	if strings.Contains(string(data), `"old"`) {
+		t.Errorf("expected the file to no longer hold the expired state:\n%s", data)
+	}
+}
+
+func TestNilProgress(t *testing.T) {
+	var p *Progress
+	if p.Done() || p.Resume() != 0 || p.Advance(10) != nil || p.Complete() != nil {
+		t.Error("expected a nil progress to record nothing")
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{URL: ""},
+		{URL: "dynamodb://"},
+		{URL: "s3://bucket"},
+		{URL: "memory://", TTL: "-1h"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package checkpoint
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DynamoDBStore keeps each object's state as a JSON document in the string
+// attribute "state". The table's partition key is the string attribute "id",
+// and TTL should be enabled on the number attribute "expires_at".
+type DynamoDBStore struct {
+	Table  string
+	TTL    time.Duration
+	Client dynamodbiface.DynamoDBAPI
+}
+
+func (d *DynamoDBStore) Get(id string) (*State, error) {
+	out, err := d.Client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(d.Table),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(id)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	attr, ok := out.Item["state"]
+	if !ok {
+		return nil, nil
+	}
+	var s State
+	if err := json.Unmarshal([]byte(aws.StringValue(attr.S)), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (d *DynamoDBStore) Put(id string, s *State) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	_, err = d.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(d.Table),
+		Item: map[string]*dynamodb.AttributeValue{
+			"id":         {S: aws.String(id)},
+			"state":      {S: aws.String(string(data))},
+			"expires_at": {N: aws.String(strconv.FormatInt(time.Now().Add(d.TTL).Unix(), 10))},
+		},
+	})
+	return err
+}
+
+// MemoryStore keeps state for the life of the process. States not updated
+// within TTL are dropped.
+type MemoryStore struct {
+	TTL time.Duration
+
+	mu     sync.Mutex
+	states map[string]State
+	now    func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore(ttl time.Duration) *MemoryStore {
+	return &MemoryStore{TTL: ttl, states: make(map[string]State), now: time.Now}
+}
+
+func (m *MemoryStore) Get(id string) (*State, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prune(m.states, m.now(), m.TTL)
+	s, ok := m.states[id]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (m *MemoryStore) Put(id string, s *State) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	prune(m.states, m.now(), m.TTL)
+	m.states[id] = *s
+	return nil
+}
+
+// FileStore keeps every object's state in one JSON file, for running
+// locally across invocations. States not updated within TTL are dropped.
+type FileStore struct {
+	Path string
+	TTL  time.Duration
+
+	mu sync.Mutex
+}
+
+func (f *FileStore) Get(id string) (*State, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states, err := f.load()
+	if err != nil {
+		return nil, err
+	}
+	s, ok := states[id]
+	if !ok {
+		return nil, nil
+	}
+	return &s, nil
+}
+
+func (f *FileStore) Put(id string, s *State) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	states, err := f.load()
+	if err != nil {
+		return err
+	}
+	states[id] = *s
+
+	data, err := json.MarshalIndent(states, "", "  ")
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.Path, data, 0644)
+}
+
+func (f *FileStore) load() (map[string]State, error) {
+	states := make(map[string]State)
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return states, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(data, &states); err != nil {
+		return nil, err
+	}
+	prune(states, time.Now(), f.TTL)
+	return states, nil
+}
+
+// prune drops states last updated more than ttl before now. A zero ttl
+// keeps everything.
+func prune(states map[string]State, now time.Time, ttl time.Duration) {
+	if ttl <= 0 {
+		return
+	}
+	for id, s := range states {
+		if now.Sub(s.Updated) > ttl {
+			delete(states, id)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+// Package classify decides whether a CloudTrail record was made from the
+// console and whether it only read data. It prefers the fields CloudTrail
+// sets for exactly this purpose and only falls back to guessing from the
+// user agent and event name on records that lack them.
+package classify
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+)
+
+// Signals name what a decision was based on. The authoritative ones are the
+// record fields they are named after; SignalUserAgent and SignalEventName are
+// heuristics.
+const (
+	SignalConsoleCredential = "sessionCredentialFromConsole"
+	SignalHostHeader        = "tlsDetails.clientProvidedHostHeader"
+	SignalReadOnly          = "readOnly"
+	SignalManagementEvent   = "managementEvent"
+	SignalEventCategory     = "eventCategory"
+	SignalUserAgent         = "userAgent"
+	SignalEventName         = "eventName"
+)
+
+// Event categories, as recorded in eventCategory.
+const (
+	Management      = "Management"
+	Data            = "Data"
+	Insight         = "Insight"
+	NetworkActivity = "NetworkActivity"
+)
+
+// consoleAgents are user agents considered Console Actions.
+var consoleAgents = matcher.New().
+	Exact("console.amazonaws.com", "signin.amazonaws.com", "Coral/Jakarta", "Coral/Netty4", "AWS CloudWatch Console").
+	Prefix("AWS Signin", "S3Console/", "[S3Console", "Mozilla/", "[Mozilla/").
+	Regexp("console.*.amazonaws.com", "signin.*.amazonaws.com")
+
+var (
+	internalAgents = matcher.New().Regexp(`(?i)aws[\s-]internal`)
+	flowLogAgents  = matcher.New().Prefix("aws-vpc-flow-logs")
+
+	// readOnlyNames are event name prefixes that suggest a call reads data.
+	readOnlyNames = matcher.New().Prefix(
+		"Head",
+		"Describe",
+		"Test",
+		"Download",
+		"Report",
+		"Refresh",
+		"Poll",
+		"Verify",
+		"Skip",
+		"Select",
+		"Count",
+		"Detect",
+		"Lookup",
+	)
+
+	// Some events don't match AWS defined standards, so these are
+	// matched after upper casing the first letter
+	titledReadOnlyNames = matcher.New().Prefix("Get", "List", "View")
+)
+
+// Decision is the answer to a classification and the signal it rests on.
+type Decision struct {
+	Value  bool
+	Signal string
+}
+
+// Heuristic reports whether the decision was a guess because the record
+// lacked the authoritative fields.
+func (d Decision) Heuristic() bool {
+	return d.Signal == SignalUserAgent || d.Signal == SignalEventName
+}
+
+func (d Decision) String() string {
+	return fmt.Sprintf("%v by %s", d.Value, d.Signal)
+}
+
+// Console decides whether a record was made from the AWS console.
+func Console(record map[string]interface{}) Decision {
+	if fromConsole(record["sessionCredentialFromConsole"]) {
+		return Decision{true, SignalConsoleCredential}
+	}
+
+	// API endpoints are shared with the CLI and SDKs, so only console hosts
+	// are conclusive
+	if tls, ok := record["tlsDetails"].(map[string]interface{}); ok {
+		if host, ok := tls["clientProvidedHostHeader"].(string); ok && consoleHost(host) {
+			return Decision{true, SignalHostHeader}
+		}
+	}
+
+	return Decision{consoleUserAgent(record), SignalUserAgent}
+}
+
+// fromConsole reads sessionCredentialFromConsole, which CloudTrail writes as
+// the string "true" and only when it is true.
+func fromConsole(v interface{}) bool {
+	return v == "true" || v == true
+}
+
+func consoleHost(host string) bool {
+	return strings.HasSuffix(host, "console.aws.amazon.com") ||
+		strings.HasSuffix(host, "signin.aws.amazon.com")
+}
+
+// consoleUserAgent is the user agent allowlist used before CloudTrail
+// recorded where credentials came from. Records without a user agent are
+// given the benefit of the doubt.
+func consoleUserAgent(record map[string]interface{}) bool {
+	usa, ok := record["userAgent"]
+	if !ok {
+		return true
+	}
+
+	ua, _ := usa.(string)
+	if consoleAgents.Match(ua) {
+		return true
+	}
+
+	// fsx.amazonaws.com uses AWS Internal
+	// Not sure if we need to filter for just that
+	// or if this is trapping more than it should.
+	// AWS Internal, aws-internal, aws-sdk-ruby aws-internal (...)
+	if internalAgents.Match(ua) {
+		return !flowLogAgents.Match(ua)
+	}
+
+	// If we can't determine the UserAgent
+	// we consider it most likely a CLI or IaC Tool.
+	return false
+}
+
+// ReadOnly decides whether a record only read data.
+func ReadOnly(record map[string]interface{}) Decision {
+	if readOnly, ok := record["readOnly"].(bool); ok {
+		return Decision{readOnly, SignalReadOnly}
+	}
+	eventName, _ := record["eventName"].(string)
+	return Decision{readOnlyName(eventName), SignalEventName}
+}
+
+func readOnlyName(en string) bool {
+	return readOnlyNames.Match(en) || titledReadOnlyNames.Match(upperFirst(en))
+}
+
+// Category returns the record's event category and the field it was read
+// from. Records that predate eventCategory are categorized by
+// managementEvent, and failing that assumed to be management events.
+func Category(record map[string]interface{}) (string, string) {
+	if category, ok := record["eventCategory"].(string); ok && category != "" {
+		return category, SignalEventCategory
+	}
+	if management, ok := record["managementEvent"].(bool); ok {
+		if management {
+			return Management, SignalManagementEvent
+		}
+		return Data, SignalManagementEvent
+	}
+	return Management, ""
+}
+
+// upperFirst upper cases the first letter, which normalizes event names
+// such as "getObject". Only the start of the name matters for prefixes, so
+// the rest is left as is. Names that already start upper case are returned
+// unchanged; the others are copied.
+func upperFirst(s string) string {
+	if s == "" || s[0] < 'a' || s[0] > 'z' {
+		return s
+	}
+	return string(s[0]-'a'+'A') + s[1:]
+}
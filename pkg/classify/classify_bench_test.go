@@ -0,0 +1,84 @@
+package classify
+
+import (
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// linearConsoleUserAgent is the allowlist as it was before it was compiled
+// into a matcher set: a linear scan that compiles its regular expressions on
+// every call. It is kept as the baseline for BenchmarkConsoleUserAgent.
+func linearConsoleUserAgent(record map[string]interface{}) bool {
+	usa, ok := record["userAgent"]
+	if !ok {
+		return true
+	}
+
+	matchString := func(m, s string) bool {
+		v, _ := regexp.MatchString(m, s)
+		return v
+	}
+
+	switch ua, _ := usa.(string); {
+	case ua == "console.amazonaws.com":
+		return true
+	case ua == "signin.amazonaws.com":
+		return true
+	case ua == "Coral/Jakarta":
+		return true
+	case ua == "Coral/Netty4":
+		return true
+	case ua == "AWS CloudWatch Console":
+		return true
+	case strings.HasPrefix(ua, "AWS Signin"):
+		return true
+	case strings.HasPrefix(ua, "S3Console/"):
+		return true
+	case strings.HasPrefix(ua, "[S3Console"):
+		return true
+	case strings.HasPrefix(ua, "Mozilla/"):
+		return true
+	case strings.HasPrefix(ua, "[Mozilla/"):
+		return true
+	case matchString("console.*.amazonaws.com", ua):
+		return true
+	case matchString("signin.*.amazonaws.com", ua):
+		return true
+	case matchString("(?i)aws[\\s-]internal", ua):
+		return !matchString("^aws-vpc-flow-logs", ua)
+	}
+	return false
+}
+
+var benchUserAgents = []map[string]interface{}{
+	{"userAgent": "aws-cli/2.13.0 Python/3.11.4 Darwin/22.6.0 exe/x86_64 prompt/off command/ec2.describe-instances"},
+	{"userAgent": "aws-sdk-go/1.44.122 (go1.19.3; linux; amd64) APN/1.0 HashiCorp/1.0 Terraform/1.3.7 (+https://www.terraform.io)"},
+	{"userAgent": "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36"},
+	{"userAgent": "console.ec2.amazonaws.com"},
+	{"userAgent": "aws-internal/3 aws-sdk-java/1.12.488 Linux/5.10 OpenJDK_64-Bit_Server_VM/25.372-b08 java/1.8.0_372"},
+	{"userAgent": "aws-vpc-flow-logs aws-internal/3"},
+	{"userAgent": "lambda.amazonaws.com"},
+	{"userAgent": "Coral/Netty4"},
+	{},
+}
+
+func TestLinearConsoleUserAgent(t *testing.T) {
+	for _, r := range benchUserAgents {
+		if got, want := consoleUserAgent(r), linearConsoleUserAgent(r); got != want {
+			t.Errorf("%v: matcher set says %v, linear scan %v", r["userAgent"], got, want)
+		}
+	}
+}
+
+func BenchmarkConsoleUserAgent(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		consoleUserAgent(benchUserAgents[i%len(benchUserAgents)])
+	}
+}
+
+func BenchmarkConsoleUserAgentLinear(b *testing.B) {
+	for i := 0; i < b.N; i++ {
+		linearConsoleUserAgent(benchUserAgents[i%len(benchUserAgents)])
+	}
+}
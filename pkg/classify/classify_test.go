@@ -0,0 +1,79 @@
+package classify
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func record(t *testing.T, raw string) map[string]interface{} {
+	var r map[string]interface{}
+	if err := json.Unmarshal([]byte(raw), &r); err != nil {
+		t.Fatal(err)
+	}
+	return r
+}
+
+func TestConsole(t *testing.T) {
+	cases := []struct {
+		record string
+		want   Decision
+	}{
+		{`{"userAgent": "aws-cli/2.2.5", "sessionCredentialFromConsole": "true"}`, Decision{true, SignalConsoleCredential}},
+		{`{"userAgent": "aws-sdk-go/1.38", "tlsDetails": {"clientProvidedHostHeader": "us-east-1.console.aws.amazon.com"}}`, Decision{true, SignalHostHeader}},
+		{`{"userAgent": "aws-cli/2.2.5", "tlsDetails": {"clientProvidedHostHeader": "ec2.us-east-1.amazonaws.com"}}`, Decision{false, SignalUserAgent}},
+		{`{"userAgent": "Mozilla/5.0 (Macintosh)"}`, Decision{true, SignalUserAgent}},
+		{`{"userAgent": "console.ec2.amazonaws.com"}`, Decision{true, SignalUserAgent}},
+		{`{"userAgent": "aws-internal/3 aws-sdk-java/1.11"}`, Decision{true, SignalUserAgent}},
+		{`{"userAgent": "aws-vpc-flow-logs aws-internal/3"}`, Decision{false, SignalUserAgent}},
+		{`{}`, Decision{true, SignalUserAgent}},
+	}
+
+	for _, c := range cases {
+		if got := Console(record(t, c.record)); got != c.want {
+			t.Errorf("%s: got %v, want %v", c.record, got, c.want)
+		}
+	}
+}
+
+func TestReadOnly(t *testing.T) {
+	cases := []struct {
+		record string
+		want   Decision
+	}{
+		{`{"eventName": "AccessKubernetesApi", "readOnly": true}`, Decision{true, SignalReadOnly}},
+		{`{"eventName": "GetSessionToken", "readOnly": false}`, Decision{false, SignalReadOnly}},
+		{`{"eventName": "getObject"}`, Decision{true, SignalEventName}},
+		{`{"eventName": "DescribeInstances"}`, Decision{true, SignalEventName}},
+		{`{"eventName": "RunInstances"}`, Decision{false, SignalEventName}},
+	}
+
+	for _, c := range cases {
+		got := ReadOnly(record(t, c.record))
+		if got != c.want {
+			t.Errorf("%s: got %v, want %v", c.record, got, c.want)
+		}
+		if got.Heuristic() != (c.want.Signal == SignalEventName) {
+			t.Errorf("%s: unexpected Heuristic() %v", c.record, got.Heuristic())
+		}
+	}
+}
+
+func TestCategory(t *testing.T) {
+	cases := []struct {
+		record   string
+		category string
+		signal   string
+	}{
+		{`{"eventCategory": "Insight", "managementEvent": true}`, Insight, SignalEventCategory},
+		{`{"managementEvent": false}`, Data, SignalManagementEvent},
+		{`{"managementEvent": true}`, Management, SignalManagementEvent},
+		{`{}`, Management, ""},
+	}
+
+	for _, c := range cases {
+		category, signal := Category(record(t, c.record))
+		if category != c.category || signal != c.signal {
+			t.Errorf("%s: got %s by %q, want %s by %q", c.record, category, signal, c.category, c.signal)
+		}
+	}
+}
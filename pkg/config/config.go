@@ -0,0 +1,135 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/archive"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/checkpoint"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/deadletter"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/dedup"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/detect"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/notify"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/override"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/principal"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/route"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/suppress"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/watchlist"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+)
+
+// DefaultDestination is the name given to the destination built from
+// SLACK_WEBHOOK and SLACK_CHANNEL.
+const DefaultDestination = "slack"
+
+// Config is the optional configuration file referenced by CONFIG_FILE.
+type Config struct {
+	Accounts     account.Directory             `json:"accounts"`
+	Destinations map[string]notify.Destination `json:"destinations"`
+	Routes       route.Table                   `json:"routes"`
+	Severity     severity.Table                `json:"severity"`
+	Watchlist    watchlist.List                `json:"watchlist"`
+	SignIn       detect.SignIn                 `json:"signin"`
+	Archive      *archive.Config               `json:"archive"`
+	DeadLetter   *deadletter.Config            `json:"dead_letter"`
+	Dedup        *dedup.Config                 `json:"dedup"`
+	Checkpoint   *checkpoint.Config            `json:"checkpoint"`
+	Overrides    []override.Override           `json:"overrides"`
+	Suppressions []suppress.Rule               `json:"suppressions"`
+	Principals   principal.Lists               `json:"principals"`
+}
+
+// FromEnv loads the file named by CONFIG_FILE, if any, and layers the
+// SLACK_WEBHOOK and SLACK_CHANNEL variables on top as the default route.
+func FromEnv() (*Config, error) {
+	cfg := &Config{}
+	if location, ok := os.LookupEnv("CONFIG_FILE"); ok && location != "" {
+		var err error
+		cfg, err = Load(location)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if cfg.Accounts == nil {
+		cfg.Accounts = account.Directory{}
+	}
+	if cfg.Destinations == nil {
+		cfg.Destinations = make(map[string]notify.Destination)
+	}
+
+	if webhook, ok := os.LookupEnv("SLACK_WEBHOOK"); ok {
+		if _, exists := cfg.Destinations[DefaultDestination]; !exists {
+			cfg.Destinations[DefaultDestination] = notify.Destination{
+				Type:    "slack",
+				URL:     webhook,
+				Channel: os.Getenv("SLACK_CHANNEL"),
+			}
+		}
+		if len(cfg.Routes.Default) == 0 {
+			cfg.Routes.Default = []route.Target{{Destination: DefaultDestination}}
+		}
+	}
+
+	return cfg, nil
+}
+
+// Load reads a JSON configuration from a local path or an s3://bucket/key URI.
+func Load(location string) (*Config, error) {
+	data, err := read(location)
+	if err != nil {
+		return nil, fmt.Errorf("reading config %s: %v", location, err)
+	}
+
+	var cfg Config
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %v", location, err)
+	}
+	if err := cfg.Severity.Validate(); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %v", location, err)
+	}
+	if err := override.Validate(cfg.Overrides); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %v", location, err)
+	}
+	if err := suppress.Validate(cfg.Suppressions); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %v", location, err)
+	}
+	if err := cfg.Principals.Validate(); err != nil {
+		return nil, fmt.Errorf("parsing config %s: %v", location, err)
+	}
+
+	return &cfg, nil
+}
+
+func read(location string) ([]byte, error) {
+	if !strings.HasPrefix(location, "s3://") {
+		return ioutil.ReadFile(location)
+	}
+
+	parts := strings.SplitN(strings.TrimPrefix(location, "s3://"), "/", 2)
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return nil, fmt.Errorf("invalid s3 uri")
+	}
+
+	s3Client := s3.New(session.Must(session.NewSession()))
+	obj, err := s3Client.GetObject(&s3.GetObjectInput{
+		Bucket: aws.String(parts[0]),
+		Key:    aws.String(parts[1]),
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Body.Close()
+
+	return ioutil.ReadAll(obj.Body)
+}
@@ -0,0 +1,186 @@
+package deadletter
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/notify"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/sqs"
+)
+
+// Config is the dead_letter section of the configuration file. URL selects the
+// backend: s3://bucket/prefix, an SQS queue URL, or a local directory given as
+// file:///path or a plain path.
+type Config struct {
+	URL      string `json:"url"`
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint"`
+}
+
+// Entry is a message that could not be delivered. Payload holds the rendered
+// message when the notifier reported it, Actions the events it carried.
+// Records holds each action's raw CloudTrail record, which actions do not
+// serialize, so that formats such as OCSF render in full on replay.
+type Entry struct {
+	ID          string                   `json:"id"`
+	Time        time.Time                `json:"time"`
+	Destination string                   `json:"destination"`
+	Channel     string                   `json:"channel,omitempty"`
+	Error       string                   `json:"error"`
+	Attempts    int                      `json:"attempts"`
+	Payload     string                   `json:"payload,omitempty"`
+	Actions     []*action.Action         `json:"actions"`
+	Records     []map[string]interface{} `json:"records,omitempty"`
+}
+
+// Store persists dead lettered entries.
+type Store interface {
+	Put(e *Entry) error
+
+	// Drain calls fn once for every stored entry. Entries fn returns nil for
+	// are removed, the others are kept with their attempt count and error
+	// updated.
+	Drain(fn func(e *Entry) error) error
+}
+
+// New returns the Store cfg.URL points at.
+func New(cfg Config) (Store, error) {
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("dead_letter requires a url")
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	awsCfg := aws.NewConfig()
+	if cfg.Region != "" {
+		awsCfg = awsCfg.WithRegion(cfg.Region)
+	}
+	if cfg.Endpoint != "" {
+		awsCfg = awsCfg.WithEndpoint(cfg.Endpoint).WithS3ForcePathStyle(true)
+	}
+
+	switch u.Scheme {
+	case "s3":
+		return &S3Store{
+			Bucket: u.Host,
+			Prefix: strings.Trim(u.Path, "/"),
+			Client: s3.New(session.Must(session.NewSession()), awsCfg),
+		}, nil
+	case "https", "http":
+		return &SQSStore{
+			QueueURL: cfg.URL,
+			Client:   sqs.New(session.Must(session.NewSession()), awsCfg),
+		}, nil
+	case "file":
+		return &FileStore{Dir: u.Path}, nil
+	case "":
+		return &FileStore{Dir: cfg.URL}, nil
+	}
+	return nil, fmt.Errorf("unsupported dead_letter url %q", cfg.URL)
+}
+
+// Entries converts a failed Notify call into dead letter entries. A
+// DeliveryError produces one entry per failed message with its rendered
+// payload, any other error a single entry holding every action sent to the
+// destination.
+func Entries(destination, channel string, groups []*digest.Group, err error) []*Entry {
+	var delivery *notify.DeliveryError
+	if !errors.As(err, &delivery) {
+		return []*Entry{newEntry(destination, channel, err.Error(), nil, digest.Flatten(groups))}
+	}
+
+	var entries []*Entry
+	for _, f := range delivery.Failures {
+		entries = append(entries, newEntry(destination, channel, f.Err.Error(), f.Payload, f.Actions))
+	}
+	return entries
+}
+
+func newEntry(destination, channel, errText string, payload []byte, actions []*action.Action) *Entry {
+	records := make([]map[string]interface{}, len(actions))
+	for i, a := range actions {
+		records[i] = a.Record
+	}
+	return &Entry{
+		ID:          newID(),
+		Time:        time.Now().UTC(),
+		Destination: destination,
+		Channel:     channel,
+		Error:       errText,
+		Attempts:    1,
+		Payload:     string(payload),
+		Actions:     actions,
+		Records:     records,
+	}
+}
+
+// newID returns a time ordered, unique entry ID.
+func newID() string {
+	b := make([]byte, 8)
+	rand.Read(b)
+	return time.Now().UTC().Format("20060102T150405Z") + "-" + hex.EncodeToString(b)
+}
+
+// Replay re-attempts delivery of every entry in store. Entries with a payload
+// are resent as is when the destination supports it, the others are rendered
+// again from their actions. It returns the number delivered and still failing.
+func Replay(store Store, notifiers *notify.Set) (delivered, failed int, err error) {
+	err = store.Drain(func(e *Entry) error {
+		if err := replay(e, notifiers); err != nil {
+			failed++
+			return err
+		}
+		delivered++
+		return nil
+	})
+	return delivered, failed, err
+}
+
+func replay(e *Entry, notifiers *notify.Set) error {
+	n, err := notifiers.Get(e.Destination, e.Channel)
+	if err != nil {
+		return err
+	}
+
+	if r, ok := n.(notify.Resender); ok && e.Payload != "" {
+		return r.Resend([]byte(e.Payload))
+	}
+	for i, a := range e.Actions {
+		if i < len(e.Records) {
+			a.Record = e.Records[i]
+		}
+	}
+	return n.Notify(digest.Build(e.Actions, digest.ModeOff, digest.DefaultThreshold))
+}
+
+func encode(e *Entry) ([]byte, error) {
+	return json.MarshalIndent(e, "", "  ")
+}
+
+func decode(data []byte) (*Entry, error) {
+	var e Entry
+	if err := json.Unmarshal(data, &e); err != nil {
+		return nil, err
+	}
+	return &e, nil
+}
+
+// retried records a failed replay attempt on e.
+func retried(e *Entry, err error) {
+	e.Attempts++
+	e.Error = err.Error()
+}
@@ -0,0 +1,366 @@
+package deadletter
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/notify"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// fakeSlack fails every request until up is set.
+type fakeSlack struct {
+	mu     sync.Mutex
+	up     bool
+	bodies []string
+}
+
+func (f *fakeSlack) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !f.up {
+		fmt.Fprint(w, "invalid_token")
+		return
+	}
+	body, _ := ioutil.ReadAll(r.Body)
+	f.bodies = append(f.bodies, string(body))
+	fmt.Fprint(w, "ok")
+}
+
+func testActions(n int) []*action.Action {
+	var actions []*action.Action
+	for i := 0; i < n; i++ {
+		actions = append(actions, &action.Action{
+			EventID:     fmt.Sprintf("event-%d", i),
+			EventName:   "CreateTags",
+			EventSource: "ec2.amazonaws.com",
+			AccountID:   "123456789012",
+		})
+	}
+	return actions
+}
+
+func TestReplayFileStore(t *testing.T) {
+	fake := &fakeSlack{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	notifiers := notify.NewSet(map[string]notify.Destination{
+		"slack": {Type: "slack", URL: srv.URL},
+	}, nil)
+	n, _ := notifiers.Get("slack", "")
+
+	groups := digest.Build(testActions(2), digest.ModeOff, digest.DefaultThreshold)
+	err := n.Notify(groups)
+	if err == nil {
+		t.Fatal("expected the slack fake to reject the messages")
+	}
+
+	entries := Entries("slack", "", groups, err)
+	if len(entries) != 2 {
+		t.Fatalf("expected an entry per failed message, got %d", len(entries))
+	}
+	if !strings.Contains(entries[0].Payload, "CreateTags") || !strings.Contains(entries[0].Error, "invalid_token") {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	store, err := New(Config{URL: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range entries {
+		if err := store.Put(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Still failing, entries are kept with another attempt recorded
+	delivered, failed, err := Replay(store, notifiers)
+	if err != nil || delivered != 0 || failed != 2 {
+		t.Fatalf("expected 2 failures, got %d delivered %d failed: %v", delivered, failed, err)
+	}
+	store.Drain(func(e *Entry) error {
+		if e.Attempts != 2 {
+			t.Errorf("expected 2 attempts, got %d", e.Attempts)
+		}
+		return errors.New("keep")
+	})
+
+	fake.up = true
+	delivered, failed, err = Replay(store, notifiers)
+	if err != nil || delivered != 2 || failed != 0 {
+		t.Fatalf("expected 2 deliveries, got %d delivered %d failed: %v", delivered, failed, err)
+	}
+	if len(fake.bodies) != 2 || fake.bodies[0] != entries[0].Payload && fake.bodies[0] != entries[1].Payload {
+		t.Errorf("expected the stored payloads to be resent, got %v", fake.bodies)
+	}
+
+	delivered, _, _ = Replay(store, notifiers)
+	if delivered != 0 {
+		t.Errorf("expected an empty store, replayed %d", delivered)
+	}
+}
+
+func TestPartialFailure(t *testing.T) {
+	// Rejects event-1 until up is set
+	var mu sync.Mutex
+	var up bool
+	var bodies []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		if !up && bytes.Contains(body, []byte("event-1")) {
+			http.Error(w, "bad event", http.StatusBadRequest)
+			return
+		}
+		bodies = append(bodies, string(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	notifiers := notify.NewSet(map[string]notify.Destination{
+		"datadog": {Type: "datadog", URL: srv.URL, Options: map[string]string{"api_key": "key"}},
+	}, nil)
+	n, _ := notifiers.Get("datadog", "")
+
+	groups := digest.Build(testActions(3), digest.ModeOff, digest.DefaultThreshold)
+	notifyErr := n.Notify(groups)
+	if notifyErr == nil || len(bodies) != 2 {
+		t.Fatalf("expected 2 of 3 events delivered, got %d: %v", len(bodies), notifyErr)
+	}
+
+	store, err := New(Config{URL: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range Entries("datadog", "", groups, notifyErr) {
+		if err := store.Put(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var stored []*Entry
+	store.Drain(func(e *Entry) error {
+		stored = append(stored, e)
+		return errors.New("keep")
+	})
+	if len(stored) != 1 || len(stored[0].Actions) != 1 || stored[0].Actions[0].EventID != "event-1" {
+		t.Fatalf("expected only event-1 to be dead lettered, got %+v", stored)
+	}
+	if !strings.Contains(stored[0].Payload, "event-1") {
+		t.Errorf("expected the rendered event as the payload, got %q", stored[0].Payload)
+	}
+
+	mu.Lock()
+	up = true
+	mu.Unlock()
+	delivered, failed, err := Replay(store, notifiers)
+	if err != nil || delivered != 1 || failed != 0 {
+		t.Fatalf("expected 1 delivery, got %d delivered %d failed: %v", delivered, failed, err)
+	}
+	if len(bodies) != 3 || bodies[2] != stored[0].Payload {
+		t.Errorf("expected the stored payload to be resent, got %v", bodies)
+	}
+}
+
+func TestReplayRendersRecords(t *testing.T) {
+	var mu sync.Mutex
+	var bodies [][]byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		mu.Lock()
+		defer mu.Unlock()
+		bodies = append(bodies, body)
+	}))
+	t.Cleanup(srv.Close)
+
+	actions := testActions(1)
+	actions[0].Record = map[string]interface{}{
+		"eventName": "CreateTags",
+		"requestID": "req-1",
+		"userIdentity": map[string]interface{}{
+			"type": "IAMUser",
+			"arn":  "arn:aws:iam::123456789012:user/alice",
+		},
+	}
+	groups := digest.Build(actions, digest.ModeOff, digest.DefaultThreshold)
+
+	// Entries without a payload are rendered again from their actions
+	store, err := New(Config{URL: t.TempDir()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, e := range Entries("datadog", "", groups, errors.New("unreachable")) {
+		if err := store.Put(e); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	notifiers := notify.NewSet(map[string]notify.Destination{
+		"datadog": {Type: "datadog", Format: "ocsf", URL: srv.URL, Options: map[string]string{"api_key": "key", "mode": "logs"}},
+	}, nil)
+	if delivered, _, err := Replay(store, notifiers); err != nil || delivered != 1 {
+		t.Fatalf("expected 1 delivery, got %d: %v", delivered, err)
+	}
+
+	var logs []struct {
+		Message string `json:"message"`
+	}
+	if len(bodies) != 1 || json.Unmarshal(bodies[0], &logs) != nil || len(logs) != 1 {
+		t.Fatalf("unexpected bodies %q", bodies)
+	}
+	var event struct {
+		Actor struct {
+			User struct {
+				UID string `json:"uid"`
+			} `json:"user"`
+		} `json:"actor"`
+		API struct {
+			Request struct {
+				UID string `json:"uid"`
+			} `json:"request"`
+		} `json:"api"`
+	}
+	if err := json.Unmarshal([]byte(logs[0].Message), &event); err != nil {
+		t.Fatal(err)
+	}
+	if event.Actor.User.UID != "arn:aws:iam::123456789012:user/alice" || event.API.Request.UID != "req-1" {
+		t.Errorf("expected the record to survive the round trip, got %+v", event)
+	}
+}
+
+func TestEntriesWithoutDeliveryError(t *testing.T) {
+	groups := digest.Build(testActions(3), digest.ModeFile, digest.DefaultThreshold)
+	entries := Entries("queue", "", groups, errors.New("access denied"))
+	if len(entries) != 1 || len(entries[0].Actions) != 3 || entries[0].Payload != "" {
+		t.Fatalf("expected one entry holding every action, got %+v", entries)
+	}
+}
+
+type memS3 struct {
+	s3iface.S3API
+	objects map[string][]byte
+}
+
+func (m *memS3) PutObject(in *s3.PutObjectInput) (*s3.PutObjectOutput, error) {
+	data, _ := ioutil.ReadAll(in.Body)
+	m.objects[aws.StringValue(in.Key)] = data
+	return &s3.PutObjectOutput{}, nil
+}
+
+func (m *memS3) ListObjectsV2Pages(in *s3.ListObjectsV2Input, fn func(*s3.ListObjectsV2Output, bool) bool) error {
+	var keys []string
+	for k := range m.objects {
+		if strings.HasPrefix(k, aws.StringValue(in.Prefix)) {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	page := &s3.ListObjectsV2Output{}
+	for _, k := range keys {
+		page.Contents = append(page.Contents, &s3.Object{Key: aws.String(k)})
+	}
+	fn(page, true)
+	return nil
+}
+
+func (m *memS3) GetObject(in *s3.GetObjectInput) (*s3.GetObjectOutput, error) {
+	return &s3.GetObjectOutput{Body: ioutil.NopCloser(bytes.NewReader(m.objects[aws.StringValue(in.Key)]))}, nil
+}
+
+func (m *memS3) DeleteObject(in *s3.DeleteObjectInput) (*s3.DeleteObjectOutput, error) {
+	delete(m.objects, aws.StringValue(in.Key))
+	return &s3.DeleteObjectOutput{}, nil
+}
+
+type memSQS struct {
+	sqsiface.SQSAPI
+	next     int
+	messages map[string]string
+}
+
+func (m *memSQS) SendMessage(in *sqs.SendMessageInput) (*sqs.SendMessageOutput, error) {
+	m.next++
+	m.messages[fmt.Sprintf("r%d", m.next)] = aws.StringValue(in.MessageBody)
+	return &sqs.SendMessageOutput{}, nil
+}
+
+func (m *memSQS) ReceiveMessage(in *sqs.ReceiveMessageInput) (*sqs.ReceiveMessageOutput, error) {
+	var handles []string
+	for h := range m.messages {
+		handles = append(handles, h)
+	}
+	sort.Strings(handles)
+
+	out := &sqs.ReceiveMessageOutput{}
+	for _, h := range handles {
+		out.Messages = append(out.Messages, &sqs.Message{Body: aws.String(m.messages[h]), ReceiptHandle: aws.String(h)})
+	}
+	return out, nil
+}
+
+func (m *memSQS) DeleteMessage(in *sqs.DeleteMessageInput) (*sqs.DeleteMessageOutput, error) {
+	delete(m.messages, aws.StringValue(in.ReceiptHandle))
+	return &sqs.DeleteMessageOutput{}, nil
+}
+
+func TestAWSStores(t *testing.T) {
+	for name, store := range map[string]Store{
+		"s3":  &S3Store{Bucket: "dlq", Prefix: "failed", Client: &memS3{objects: make(map[string][]byte)}},
+		"sqs": &SQSStore{QueueURL: "https://sqs.us-east-1.amazonaws.com/123456789012/dlq", Client: &memSQS{messages: make(map[string]string)}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			for _, e := range Entries("slack", "", nil, &notify.DeliveryError{Kind: "slack", Total: 2, Failures: []notify.Failure{
+				{Payload: []byte(`{"text":"one"}`), Err: errors.New("boom")},
+				{Payload: []byte(`{"text":"two"}`), Err: errors.New("boom")},
+			}}) {
+				if err := store.Put(e); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			// Fail the first entry, deliver the second
+			var calls int
+			err := store.Drain(func(e *Entry) error {
+				calls++
+				if e.Payload == `{"text":"one"}` {
+					return errors.New("still down")
+				}
+				return nil
+			})
+			if err != nil || calls != 2 {
+				t.Fatalf("expected 2 calls, got %d: %v", calls, err)
+			}
+
+			var left []*Entry
+			store.Drain(func(e *Entry) error {
+				left = append(left, e)
+				return nil
+			})
+			if len(left) != 1 || left[0].Payload != `{"text":"one"}` || left[0].Attempts != 2 || left[0].Error != "still down" {
+				t.Fatalf("expected the failed entry to be kept, got %+v", left)
+			}
+
+			store.Drain(func(e *Entry) error {
+				t.Errorf("expected an empty store, got %s", e.ID)
+				return nil
+			})
+		})
+	}
+}
@@ -0,0 +1,218 @@
+package deadletter
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/aws/aws-sdk-go/service/s3/s3iface"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+	log "github.com/sirupsen/logrus"
+)
+
+// FileStore keeps one JSON file per entry in a local directory. It is meant
+// for development, Lambda's filesystem does not outlive the container.
+type FileStore struct {
+	Dir string
+}
+
+func (f *FileStore) Put(e *Entry) error {
+	if err := os.MkdirAll(f.Dir, 0755); err != nil {
+		return err
+	}
+	data, err := encode(e)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filepath.Join(f.Dir, e.ID+".json"), data, 0644)
+}
+
+func (f *FileStore) Drain(fn func(e *Entry) error) error {
+	names, err := filepath.Glob(filepath.Join(f.Dir, "*.json"))
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := ioutil.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		e, err := decode(data)
+		if err != nil {
+			log.Warnf("skipping %s: %v", name, err)
+			continue
+		}
+
+		if err := fn(e); err != nil {
+			retried(e, err)
+			if err := f.Put(e); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := os.Remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// S3Store keeps one JSON object per entry under a bucket prefix.
+type S3Store struct {
+	Bucket string
+	Prefix string
+	Client s3iface.S3API
+}
+
+func (s *S3Store) key(id string) string {
+	return path.Join(s.Prefix, id+".json")
+}
+
+func (s *S3Store) Put(e *Entry) error {
+	data, err := encode(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.PutObject(&s3.PutObjectInput{
+		Bucket:      aws.String(s.Bucket),
+		Key:         aws.String(s.key(e.ID)),
+		Body:        bytes.NewReader(data),
+		ContentType: aws.String("application/json"),
+	})
+	return err
+}
+
+func (s *S3Store) Drain(fn func(e *Entry) error) error {
+	prefix := s.Prefix
+	if prefix != "" {
+		prefix += "/"
+	}
+
+	var keys []string
+	err := s.Client.ListObjectsV2Pages(&s3.ListObjectsV2Input{
+		Bucket: aws.String(s.Bucket),
+		Prefix: aws.String(prefix),
+	}, func(page *s3.ListObjectsV2Output, last bool) bool {
+		for _, obj := range page.Contents {
+			if key := aws.StringValue(obj.Key); strings.HasSuffix(key, ".json") {
+				keys = append(keys, key)
+			}
+		}
+		return true
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, key := range keys {
+		obj, err := s.Client.GetObject(&s3.GetObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(obj.Body)
+		obj.Body.Close()
+		if err != nil {
+			return err
+		}
+		e, err := decode(data)
+		if err != nil {
+			log.Warnf("skipping s3://%s/%s: %v", s.Bucket, key, err)
+			continue
+		}
+
+		if err := fn(e); err != nil {
+			retried(e, err)
+			if err := s.Put(e); err != nil {
+				return err
+			}
+			continue
+		}
+		_, err = s.Client.DeleteObject(&s3.DeleteObjectInput{
+			Bucket: aws.String(s.Bucket),
+			Key:    aws.String(key),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SQSStore sends each entry as a message to a queue.
+type SQSStore struct {
+	QueueURL string
+	Client   sqsiface.SQSAPI
+}
+
+func (s *SQSStore) Put(e *Entry) error {
+	data, err := encode(e)
+	if err != nil {
+		return err
+	}
+	_, err = s.Client.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:    aws.String(s.QueueURL),
+		MessageBody: aws.String(string(data)),
+	})
+	return err
+}
+
+// Drain receives until the queue is empty. Entries that fail again are sent
+// back as new messages, and are recognised by ID so each entry is tried once
+// per Drain.
+func (s *SQSStore) Drain(fn func(e *Entry) error) error {
+	seen := make(map[string]bool)
+	for {
+		out, err := s.Client.ReceiveMessage(&sqs.ReceiveMessageInput{
+			QueueUrl:            aws.String(s.QueueURL),
+			MaxNumberOfMessages: aws.Int64(10),
+			WaitTimeSeconds:     aws.Int64(1),
+		})
+		if err != nil {
+			return err
+		}
+
+		var fresh int
+		for _, msg := range out.Messages {
+			e, err := decode([]byte(aws.StringValue(msg.Body)))
+			if err != nil {
+				log.Warnf("skipping message %s: %v", aws.StringValue(msg.MessageId), err)
+				continue
+			}
+			if seen[e.ID] {
+				continue
+			}
+			seen[e.ID] = true
+			fresh++
+
+			if err := fn(e); err != nil {
+				retried(e, err)
+				if err := s.Put(e); err != nil {
+					return err
+				}
+			}
+			_, err = s.Client.DeleteMessage(&sqs.DeleteMessageInput{
+				QueueUrl:      aws.String(s.QueueURL),
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		if fresh == 0 {
+			return nil
+		}
+	}
+}
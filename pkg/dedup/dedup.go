@@ -0,0 +1,117 @@
+package dedup
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+)
+
+// DefaultTTL is how long a delivery is remembered. It only needs to outlast
+// S3 event redelivery and SQS retries of the same file.
+const DefaultTTL = 7 * 24 * time.Hour
+
+// DefaultLease is how long a claim holds a key before it is delivered. It
+// should be at least the function's timeout, so that a claim is only taken
+// over once the invocation holding it can no longer be sending.
+const DefaultLease = 15 * time.Minute
+
+// ErrPending is returned by Claim when another invocation holds an unexpired
+// lease on the key, and may or may not go on to deliver it.
+var ErrPending = errors.New("delivery in progress")
+
+// Config is the dedup section of the configuration file. URL selects the
+// backend: dynamodb://table, memory:// or a local file given as file:///path
+// or a plain path. TTL and Lease are durations such as "72h".
+type Config struct {
+	URL      string `json:"url"`
+	TTL      string `json:"ttl"`
+	Lease    string `json:"lease"`
+	Region   string `json:"region"`
+	Endpoint string `json:"endpoint"`
+}
+
+// Store remembers which events have been delivered to which sinks. Keys are
+// claimed in two phases: Claim leases a key while it is being sent, and
+// Deliver records it once it was. A lease left by an invocation that died
+// before delivering expires, and a retry may then claim the key again.
+type Store interface {
+	// Claim leases key and reports whether it was free. It returns false
+	// for a delivered key, and ErrPending for a key leased by another
+	// invocation.
+	Claim(key string) (bool, error)
+
+	// Deliver records that key was delivered, keeping it for the TTL.
+	Deliver(key string) error
+
+	// Release forgets key so a later invocation may deliver it again.
+	Release(key string) error
+}
+
+// Key identifies the delivery of an event to a destination channel.
+func Key(eventID, destination, channel string) string {
+	key := eventID + "/" + destination
+	if channel != "" {
+		key += "/" + channel
+	}
+	return key
+}
+
+// New returns the Store cfg.URL points at.
+func New(cfg Config) (Store, error) {
+	ttl := DefaultTTL
+	if cfg.TTL != "" {
+		var err error
+		if ttl, err = time.ParseDuration(cfg.TTL); err != nil || ttl <= 0 {
+			return nil, fmt.Errorf("invalid dedup ttl %q", cfg.TTL)
+		}
+	}
+
+	lease := DefaultLease
+	if cfg.Lease != "" {
+		var err error
+		if lease, err = time.ParseDuration(cfg.Lease); err != nil || lease <= 0 {
+			return nil, fmt.Errorf("invalid dedup lease %q", cfg.Lease)
+		}
+	}
+
+	u, err := url.Parse(cfg.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "dynamodb":
+		if u.Host == "" {
+			return nil, fmt.Errorf("dedup url requires a table, e.g. dynamodb://console-actions-dedup")
+		}
+		awsCfg := aws.NewConfig()
+		if cfg.Region != "" {
+			awsCfg = awsCfg.WithRegion(cfg.Region)
+		}
+		if cfg.Endpoint != "" {
+			awsCfg = awsCfg.WithEndpoint(cfg.Endpoint)
+		}
+		return &DynamoDBStore{
+			Table:  u.Host,
+			TTL:    ttl,
+			Lease:  lease,
+			Client: dynamodb.New(session.Must(session.NewSession()), awsCfg),
+		}, nil
+	case "memory":
+		return NewMemoryStore(ttl, lease), nil
+	case "file":
+		return &FileStore{Path: u.Path, TTL: ttl, Lease: lease}, nil
+	case "":
+		if strings.TrimSpace(cfg.URL) == "" {
+			return nil, fmt.Errorf("dedup requires a url")
+		}
+		return &FileStore{Path: cfg.URL, TTL: ttl, Lease: lease}, nil
+	}
+	return nil, fmt.Errorf("unsupported dedup url %q", cfg.URL)
+}
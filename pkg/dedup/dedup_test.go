@@ -0,0 +1,144 @@
+package dedup
+
+import (
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// fakeDynamoDB evaluates the claim condition against an in-memory table.
+type fakeDynamoDB struct {
+	dynamodbiface.DynamoDBAPI
+	items map[string]map[string]*dynamodb.AttributeValue
+}
+
+func (f *fakeDynamoDB) PutItem(in *dynamodb.PutItemInput) (*dynamodb.PutItemOutput, error) {
+	id := aws.StringValue(in.Item["id"].S)
+	if in.ConditionExpression != nil {
+		now, _ := strconv.ParseInt(aws.StringValue(in.ExpressionAttributeValues[":now"].N), 10, 64)
+		if item, ok := f.items[id]; ok {
+			if expires, _ := strconv.ParseInt(aws.StringValue(item["expires_at"].N), 10, 64); expires >= now {
+				return nil, awserr.New(dynamodb.ErrCodeConditionalCheckFailedException, "The conditional request failed", nil)
+			}
+		}
+	}
+	f.items[id] = in.Item
+	return &dynamodb.PutItemOutput{}, nil
+}
+
+func (f *fakeDynamoDB) GetItem(in *dynamodb.GetItemInput) (*dynamodb.GetItemOutput, error) {
+	return &dynamodb.GetItemOutput{Item: f.items[aws.StringValue(in.Key["id"].S)]}, nil
+}
+
+func (f *fakeDynamoDB) DeleteItem(in *dynamodb.DeleteItemInput) (*dynamodb.DeleteItemOutput, error) {
+	delete(f.items, aws.StringValue(in.Key["id"].S))
+	return &dynamodb.DeleteItemOutput{}, nil
+}
+
+func newFakeDynamoDB() *fakeDynamoDB {
+	return &fakeDynamoDB{items: make(map[string]map[string]*dynamodb.AttributeValue)}
+}
+
+func TestStores(t *testing.T) {
+	file, err := New(Config{URL: filepath.Join(t.TempDir(), "dedup.json")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory, err := New(Config{URL: "memory://"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dynamo := &DynamoDBStore{Table: "dedup", TTL: time.Hour, Lease: time.Minute, Client: newFakeDynamoDB()}
+
+	for name, store := range map[string]Store{"file": file, "memory": memory, "dynamodb": dynamo} {
+		t.Run(name, func(t *testing.T) {
+			key := Key("event-1", "slack", "")
+			other := Key("event-1", "splunk", "")
+
+			if ok, err := store.Claim(key); !ok || err != nil {
+				t.Fatalf("expected the first claim to succeed, got %t: %v", ok, err)
+			}
+			if ok, err := store.Claim(key); ok || err != ErrPending {
+				t.Fatalf("expected a leased key to be pending, got %t: %v", ok, err)
+			}
+			if ok, _ := store.Claim(other); !ok {
+				t.Error("expected the same event to be claimable for another destination")
+			}
+
+			if err := store.Deliver(key); err != nil {
+				t.Fatal(err)
+			}
+			if ok, err := store.Claim(key); ok || err != nil {
+				t.Fatalf("expected a delivered key to be skipped, got %t: %v", ok, err)
+			}
+
+			if err := store.Release(key); err != nil {
+				t.Fatal(err)
+			}
+			if ok, _ := store.Claim(key); !ok {
+				t.Error("expected a released key to be claimable again")
+			}
+		})
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	now := time.Date(2021, 5, 14, 19, 0, 0, 0, time.UTC)
+
+	memory := NewMemoryStore(time.Hour, time.Minute)
+	memory.now = func() time.Time { return now }
+	dynamo := &DynamoDBStore{Table: "dedup", TTL: time.Hour, Lease: time.Minute, Client: newFakeDynamoDB(), now: func() time.Time { return now }}
+
+	for name, store := range map[string]Store{"memory": memory, "dynamodb": dynamo} {
+		now = time.Date(2021, 5, 14, 19, 0, 0, 0, time.UTC)
+		if ok, _ := store.Claim("event-1/slack"); !ok {
+			t.Fatalf("%s: expected first claim to succeed", name)
+		}
+
+		// The invocation holding the lease died before delivering
+		now = now.Add(2 * time.Minute)
+		if ok, err := store.Claim("event-1/slack"); !ok {
+			t.Fatalf("%s: expected an expired lease to be taken over, got %v", name, err)
+		}
+
+		if err := store.Deliver("event-1/slack"); err != nil {
+			t.Fatal(err)
+		}
+		now = now.Add(30 * time.Minute)
+		if ok, err := store.Claim("event-1/slack"); ok || err != nil {
+			t.Errorf("%s: expected a delivery to outlast the lease, got %t: %v", name, ok, err)
+		}
+		now = now.Add(2 * time.Hour)
+		if ok, _ := store.Claim("event-1/slack"); !ok {
+			t.Errorf("%s: expected an expired delivery to be replaced", name)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+	for _, cfg := range []Config{
+		{URL: ""},
+		{URL: "dynamodb://"},
+		{URL: "redis://localhost"},
+		{URL: "memory://", TTL: "soon"},
+		{URL: "memory://", Lease: "-1m"},
+	} {
+		if _, err := New(cfg); err == nil {
+			t.Errorf("expected an error for %+v", cfg)
+		}
+	}
+
+	store, err := New(Config{URL: "dynamodb://console-actions-dedup", TTL: "72h", Lease: "5m", Region: "us-east-1"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := store.(*DynamoDBStore); d.Table != "console-actions-dedup" || d.TTL != 72*time.Hour || d.Lease != 5*time.Minute {
+		t.Errorf("unexpected store %+v", d)
+	}
+}
@@ -0,0 +1,240 @@
+package dedup
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/dynamodb"
+	"github.com/aws/aws-sdk-go/service/dynamodb/dynamodbiface"
+)
+
+// DynamoDBStore claims keys with conditional writes. The table's partition
+// key is the string attribute "id", and TTL should be enabled on the number
+// attribute "expires_at" so old claims are removed.
+type DynamoDBStore struct {
+	Table  string
+	TTL    time.Duration
+	Lease  time.Duration
+	Client dynamodbiface.DynamoDBAPI
+
+	now func() time.Time
+}
+
+func (d *DynamoDBStore) Claim(key string) (bool, error) {
+	now := d.clock()
+	_, err := d.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(d.Table),
+		Item:      d.item(key, statePending, now.Add(d.lease())),
+		// TTL deletion lags expiry, so expired claims are overwritten too
+		ConditionExpression: aws.String("attribute_not_exists(id) OR expires_at < :now"),
+		ExpressionAttributeValues: map[string]*dynamodb.AttributeValue{
+			":now": {N: aws.String(strconv.FormatInt(now.Unix(), 10))},
+		},
+	})
+	if aerr, ok := err.(awserr.Error); ok && aerr.Code() == dynamodb.ErrCodeConditionalCheckFailedException {
+		return false, d.held(key)
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// held returns ErrPending unless the key that failed to be claimed was
+// delivered.
+func (d *DynamoDBStore) held(key string) error {
+	out, err := d.Client.GetItem(&dynamodb.GetItemInput{
+		TableName:      aws.String(d.Table),
+		Key:            map[string]*dynamodb.AttributeValue{"id": {S: aws.String(key)}},
+		ConsistentRead: aws.Bool(true),
+	})
+	if err != nil {
+		return err
+	}
+	if state := out.Item["state"]; state != nil && aws.StringValue(state.S) == stateDelivered {
+		return nil
+	}
+	return ErrPending
+}
+
+func (d *DynamoDBStore) Deliver(key string) error {
+	_, err := d.Client.PutItem(&dynamodb.PutItemInput{
+		TableName: aws.String(d.Table),
+		Item:      d.item(key, stateDelivered, d.clock().Add(d.TTL)),
+	})
+	return err
+}
+
+func (d *DynamoDBStore) Release(key string) error {
+	_, err := d.Client.DeleteItem(&dynamodb.DeleteItemInput{
+		TableName: aws.String(d.Table),
+		Key: map[string]*dynamodb.AttributeValue{
+			"id": {S: aws.String(key)},
+		},
+	})
+	return err
+}
+
+func (d *DynamoDBStore) item(key, state string, expires time.Time) map[string]*dynamodb.AttributeValue {
+	return map[string]*dynamodb.AttributeValue{
+		"id":         {S: aws.String(key)},
+		"state":      {S: aws.String(state)},
+		"expires_at": {N: aws.String(strconv.FormatInt(expires.Unix(), 10))},
+	}
+}
+
+func (d *DynamoDBStore) lease() time.Duration {
+	if d.Lease > 0 {
+		return d.Lease
+	}
+	return DefaultLease
+}
+
+func (d *DynamoDBStore) clock() time.Time {
+	if d.now != nil {
+		return d.now()
+	}
+	return time.Now()
+}
+
+// MemoryStore keeps claims for the life of the process, which on Lambda is
+// the life of the container.
+type MemoryStore struct {
+	TTL   time.Duration
+	Lease time.Duration
+
+	mu     sync.Mutex
+	claims map[string]claimed
+	now    func() time.Time
+}
+
+// NewMemoryStore returns an empty MemoryStore.
+func NewMemoryStore(ttl, lease time.Duration) *MemoryStore {
+	return &MemoryStore{TTL: ttl, Lease: lease, claims: make(map[string]claimed), now: time.Now}
+}
+
+func (m *MemoryStore) Claim(key string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return claim(m.claims, key, m.now(), m.Lease)
+}
+
+func (m *MemoryStore) Deliver(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.claims[key] = claimed{State: stateDelivered, Expires: m.now().Add(m.TTL)}
+	return nil
+}
+
+func (m *MemoryStore) Release(key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.claims, key)
+	return nil
+}
+
+// FileStore keeps claims in a JSON file, for running locally across
+// invocations.
+type FileStore struct {
+	Path  string
+	TTL   time.Duration
+	Lease time.Duration
+
+	mu sync.Mutex
+}
+
+func (f *FileStore) Claim(key string) (bool, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	claims, err := f.load()
+	if err != nil {
+		return false, err
+	}
+	if ok, err := claim(claims, key, time.Now(), f.Lease); !ok {
+		return false, err
+	}
+	return true, f.save(claims)
+}
+
+func (f *FileStore) Deliver(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	claims, err := f.load()
+	if err != nil {
+		return err
+	}
+	claims[key] = claimed{State: stateDelivered, Expires: time.Now().Add(f.TTL)}
+	return f.save(claims)
+}
+
+func (f *FileStore) Release(key string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	claims, err := f.load()
+	if err != nil {
+		return err
+	}
+	delete(claims, key)
+	return f.save(claims)
+}
+
+func (f *FileStore) load() (map[string]claimed, error) {
+	claims := make(map[string]claimed)
+	data, err := ioutil.ReadFile(f.Path)
+	if os.IsNotExist(err) {
+		return claims, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return claims, json.Unmarshal(data, &claims)
+}
+
+func (f *FileStore) save(claims map[string]claimed) error {
+	data, err := json.Marshal(claims)
+	if err != nil {
+		return err
+	}
+	return ioutil.WriteFile(f.Path, data, 0644)
+}
+
+const (
+	statePending   = "pending"
+	stateDelivered = "delivered"
+)
+
+// claimed is a key's state in the memory and file stores.
+type claimed struct {
+	State   string    `json:"state"`
+	Expires time.Time `json:"expires"`
+}
+
+// claim leases key in claims unless an unexpired claim exists, dropping
+// expired claims as it goes.
+func claim(claims map[string]claimed, key string, now time.Time, lease time.Duration) (bool, error) {
+	for k, c := range claims {
+		if !c.Expires.After(now) {
+			delete(claims, k)
+		}
+	}
+	if c, ok := claims[key]; ok {
+		if c.State == stateDelivered {
+			return false, nil
+		}
+		return false, ErrPending
+	}
+	if lease <= 0 {
+		lease = DefaultLease
+	}
+	claims[key] = claimed{State: statePending, Expires: now.Add(lease)}
+	return true, nil
+}
@@ -0,0 +1,40 @@
+package detect
+
+import "strings"
+
+// RootTag marks actions taken with the account's root credentials.
+const RootTag = "root"
+
+// Kinds of root credential use.
+const (
+	RootSignIn         = "sign-in"
+	RootPasswordChange = "password-change"
+	RootMFAChange      = "mfa-change"
+	RootAccessKey      = "access-key"
+	RootAPICall        = "api-call"
+)
+
+// Root reports whether a record was made with root credentials and what kind
+// of activity it was. AWS recommends the root user is never used day to day,
+// so every use is worth a look, including sign-ins.
+func Root(record map[string]interface{}) (string, bool) {
+	userIdentity, _ := record["userIdentity"].(map[string]interface{})
+	if userIdentity["type"] != "Root" {
+		return "", false
+	}
+
+	eventName, _ := record["eventName"].(string)
+	switch {
+	case eventName == "ConsoleLogin":
+		return RootSignIn, true
+	case strings.HasPrefix(eventName, "PasswordRecovery"),
+		eventName == "ChangePassword",
+		eventName == "UpdateLoginProfile":
+		return RootPasswordChange, true
+	case strings.HasSuffix(eventName, "MFADevice"):
+		return RootMFAChange, true
+	case eventName == "CreateAccessKey", eventName == "UpdateAccessKey", eventName == "DeleteAccessKey":
+		return RootAccessKey, true
+	}
+	return RootAPICall, true
+}
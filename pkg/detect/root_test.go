@@ -0,0 +1,35 @@
+package detect
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestRoot(t *testing.T) {
+	cases := []struct {
+		record string
+		want   string
+		root   bool
+	}{
+		{`{"eventName": "ConsoleLogin", "userIdentity": {"type": "Root"}}`, RootSignIn, true},
+		{`{"eventName": "PasswordRecoveryCompleted", "userIdentity": {"type": "Root"}}`, RootPasswordChange, true},
+		{`{"eventName": "ChangePassword", "userIdentity": {"type": "Root"}}`, RootPasswordChange, true},
+		{`{"eventName": "EnableMFADevice", "userIdentity": {"type": "Root"}}`, RootMFAChange, true},
+		{`{"eventName": "DeactivateMFADevice", "userIdentity": {"type": "Root"}}`, RootMFAChange, true},
+		{`{"eventName": "CreateAccessKey", "userIdentity": {"type": "Root"}}`, RootAccessKey, true},
+		{`{"eventName": "RunInstances", "userIdentity": {"type": "Root"}}`, RootAPICall, true},
+		{`{"eventName": "ConsoleLogin", "userIdentity": {"type": "IAMUser"}}`, "", false},
+		{`{"eventName": "ConsoleLogin"}`, "", false},
+	}
+
+	for _, c := range cases {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(c.record), &record); err != nil {
+			t.Fatal(err)
+		}
+		got, root := Root(record)
+		if got != c.want || root != c.root {
+			t.Errorf("%s: got %q, %v, want %q, %v", c.record, got, root, c.want, c.root)
+		}
+	}
+}
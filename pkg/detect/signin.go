@@ -0,0 +1,163 @@
+package detect
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Tags for sign-ins kept by the SignIn monitor.
+const (
+	SignInTag     = "signin"
+	BruteForceTag = "brute-force"
+)
+
+// Reasons a sign-in is worth surfacing.
+const (
+	SignInFailure = "failure"
+	SignInNoMFA   = "no-mfa"
+	SignInIAMUser = "iam-user"
+)
+
+// DefaultBruteForceThreshold is how many failed sign-ins for one user from
+// one address a file must hold before they are reported as a brute force.
+const DefaultBruteForceThreshold = 5
+
+// SignIn is the signin section of the configuration file. The monitor is off
+// unless enabled, in which case sign-in events that are otherwise dropped are
+// kept when they failed, skipped MFA, or came from an IAM user rather than
+// SSO.
+type SignIn struct {
+	Enabled             bool `json:"enabled"`
+	BruteForceThreshold int  `json:"brute_force_threshold"`
+}
+
+// signInEvents are the signin.amazonaws.com events the monitor inspects.
+var signInEvents = map[string]bool{
+	"ConsoleLogin":       true,
+	"CheckMfa":           true,
+	"UserAuthentication": true,
+}
+
+// Check returns why a sign-in record should be kept, or nothing when it is
+// not a sign-in, is unremarkable, or the monitor is disabled.
+func (s *SignIn) Check(record map[string]interface{}) []string {
+	if !s.Enabled || !isSignIn(record) {
+		return nil
+	}
+	if failed(record) {
+		return []string{SignInFailure}
+	}
+	if record["eventName"] != "ConsoleLogin" {
+		return nil
+	}
+
+	var reasons []string
+	if aed, ok := record["additionalEventData"].(map[string]interface{}); ok && aed["MFAUsed"] == "No" {
+		reasons = append(reasons, SignInNoMFA)
+	}
+	// SSO users sign in through an assumed role, so IAMUser means a long
+	// lived password
+	if userIdentity, _ := record["userIdentity"].(map[string]interface{}); userIdentity["type"] == "IAMUser" {
+		reasons = append(reasons, SignInIAMUser)
+	}
+	return reasons
+}
+
+// Describe is a short human readable summary of the reasons.
+func Describe(reasons []string) string {
+	var parts []string
+	for _, r := range reasons {
+		switch r {
+		case SignInFailure:
+			parts = append(parts, "failed sign-in")
+		case SignInNoMFA:
+			parts = append(parts, "signed in without MFA")
+		case SignInIAMUser:
+			parts = append(parts, "IAM user sign-in")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// Burst is a run of failed sign-ins for one user from one source IP.
+type Burst struct {
+	User     string
+	SourceIP string
+	Count    int
+
+	// Last is the index of the final failure, which stands in for the
+	// whole burst.
+	Last int
+}
+
+func (b *Burst) String() string {
+	return fmt.Sprintf("%d failed sign-ins for %s from %s", b.Count, b.User, b.SourceIP)
+}
+
+// Bursts groups a file's failed sign-ins by user and source IP and returns
+// the groups that reach the brute force threshold, keyed by the index of
+// every record in them.
+func (s *SignIn) Bursts(records []map[string]interface{}) map[int]*Burst {
+	if !s.Enabled {
+		return nil
+	}
+	threshold := s.BruteForceThreshold
+	if threshold <= 0 {
+		threshold = DefaultBruteForceThreshold
+	}
+
+	groups := make(map[string]*Burst)
+	members := make(map[string][]int)
+	for i, record := range records {
+		if !isSignIn(record) || !failed(record) {
+			continue
+		}
+		user := signInUser(record)
+		ip, _ := record["sourceIPAddress"].(string)
+		key := user + "|" + ip
+		if groups[key] == nil {
+			groups[key] = &Burst{User: user, SourceIP: ip}
+		}
+		groups[key].Count++
+		groups[key].Last = i
+		members[key] = append(members[key], i)
+	}
+
+	bursts := make(map[int]*Burst)
+	for key, b := range groups {
+		if b.Count < threshold {
+			continue
+		}
+		for _, i := range members[key] {
+			bursts[i] = b
+		}
+	}
+	return bursts
+}
+
+func isSignIn(record map[string]interface{}) bool {
+	eventName, _ := record["eventName"].(string)
+	return record["eventSource"] == "signin.amazonaws.com" && signInEvents[eventName]
+}
+
+// failed reports whether a sign-in was rejected. The outcome is recorded in
+// responseElements under the event's name, e.g. {"ConsoleLogin": "Failure"}.
+func failed(record map[string]interface{}) bool {
+	eventName, _ := record["eventName"].(string)
+	if rps, ok := record["responseElements"].(map[string]interface{}); ok && rps[eventName] == "Failure" {
+		return true
+	}
+	return record["errorMessage"] != nil
+}
+
+// signInUser names the principal a sign-in was for. Failed sign-ins for
+// unknown users carry little more than a placeholder user name.
+func signInUser(record map[string]interface{}) string {
+	userIdentity, _ := record["userIdentity"].(map[string]interface{})
+	for _, field := range []string{"userName", "arn", "principalId"} {
+		if v, ok := userIdentity[field].(string); ok && v != "" {
+			return v
+		}
+	}
+	return "unknown"
+}
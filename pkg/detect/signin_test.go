@@ -0,0 +1,78 @@
+package detect
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func records(t *testing.T, raw ...string) []map[string]interface{} {
+	var out []map[string]interface{}
+	for _, r := range raw {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(r), &record); err != nil {
+			t.Fatal(err)
+		}
+		out = append(out, record)
+	}
+	return out
+}
+
+const (
+	failedLogin = `{"eventSource": "signin.amazonaws.com", "eventName": "ConsoleLogin", "sourceIPAddress": "203.0.113.7", "userIdentity": {"type": "IAMUser", "userName": "alice"}, "responseElements": {"ConsoleLogin": "Failure"}, "errorMessage": "Failed authentication"}`
+	ssoLogin    = `{"eventSource": "signin.amazonaws.com", "eventName": "ConsoleLogin", "userIdentity": {"type": "AssumedRole"}, "responseElements": {"ConsoleLogin": "Success"}, "additionalEventData": {"MFAUsed": "Yes"}}`
+	iamLogin    = `{"eventSource": "signin.amazonaws.com", "eventName": "ConsoleLogin", "userIdentity": {"type": "IAMUser", "userName": "bob"}, "responseElements": {"ConsoleLogin": "Success"}, "additionalEventData": {"MFAUsed": "No"}}`
+	failedMfa   = `{"eventSource": "signin.amazonaws.com", "eventName": "CheckMfa", "sourceIPAddress": "203.0.113.7", "userIdentity": {"type": "IAMUser", "userName": "alice"}, "responseElements": {"CheckMfa": "Failure"}}`
+	okMfa       = `{"eventSource": "signin.amazonaws.com", "eventName": "CheckMfa", "userIdentity": {"type": "IAMUser", "userName": "alice"}, "responseElements": {"CheckMfa": "Success"}}`
+)
+
+func TestSignInCheck(t *testing.T) {
+	rs := records(t, failedLogin, ssoLogin, iamLogin, failedMfa, okMfa)
+	want := [][]string{
+		{SignInFailure},
+		nil,
+		{SignInNoMFA, SignInIAMUser},
+		{SignInFailure},
+		nil,
+	}
+
+	if got := (&SignIn{}).Check(rs[0]); got != nil {
+		t.Errorf("expected a disabled monitor to keep nothing, got %v", got)
+	}
+
+	monitor := &SignIn{Enabled: true}
+	for i, r := range rs {
+		if got := monitor.Check(r); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("record %d: got %v, want %v", i, got, want[i])
+		}
+	}
+
+	if got := Describe(want[2]); got != "signed in without MFA, IAM user sign-in" {
+		t.Errorf("unexpected description %q", got)
+	}
+}
+
+func TestSignInBursts(t *testing.T) {
+	other := `{"eventSource": "signin.amazonaws.com", "eventName": "ConsoleLogin", "sourceIPAddress": "198.51.100.1", "userIdentity": {"type": "IAMUser", "userName": "alice"}, "responseElements": {"ConsoleLogin": "Failure"}}`
+	rs := records(t, failedLogin, ssoLogin, failedLogin, other, failedMfa)
+
+	monitor := &SignIn{Enabled: true, BruteForceThreshold: 3}
+	bursts := monitor.Bursts(rs)
+	if len(bursts) != 3 {
+		t.Fatalf("expected the three failures from one address to form a burst, got %v", bursts)
+	}
+	for _, i := range []int{0, 2, 4} {
+		b := bursts[i]
+		if b == nil || b.Last != 4 || b.Count != 3 {
+			t.Errorf("record %d: unexpected burst %+v", i, b)
+		}
+	}
+	if got := bursts[4].String(); got != "3 failed sign-ins for alice from 203.0.113.7" {
+		t.Errorf("unexpected summary %q", got)
+	}
+
+	monitor.BruteForceThreshold = 0
+	if bursts := monitor.Bursts(rs); len(bursts) != 0 {
+		t.Errorf("expected the default threshold to need more failures, got %v", bursts)
+	}
+}
@@ -0,0 +1,180 @@
+package digest
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+)
+
+// Mode controls how kept actions are grouped into digests.
+type Mode string
+
+const (
+	// ModeOff sends every action on its own.
+	ModeOff Mode = ""
+	// ModeUser groups actions by account and user.
+	ModeUser Mode = "user"
+	// ModeFile groups every action from the same CloudTrail file.
+	ModeFile Mode = "file"
+)
+
+// DefaultThreshold is the smallest group that gets summarized.
+const DefaultThreshold = 2
+
+// ParseMode validates a mode string such as the DIGEST_MODE variable.
+func ParseMode(s string) (Mode, error) {
+	switch m := Mode(strings.ToLower(strings.TrimSpace(s))); m {
+	case ModeOff, ModeUser, ModeFile:
+		return m, nil
+	case "off", "none":
+		return ModeOff, nil
+	}
+	return ModeOff, fmt.Errorf("unknown digest mode %q", s)
+}
+
+// Group is a set of actions delivered as a single message.
+type Group struct {
+	Key     string
+	Actions []*action.Action
+}
+
+// Count is the number of times an event name appears in a Group.
+type Count struct {
+	EventName string
+	Count     int
+}
+
+// Build groups actions according to mode. Groups smaller than threshold are
+// split back into single-action groups so they still post individually.
+// Ordering follows the first appearance of each group in actions.
+func Build(actions []*action.Action, mode Mode, threshold int) []*Group {
+	if threshold < 1 {
+		threshold = DefaultThreshold
+	}
+
+	var groups []*Group
+	if mode == ModeOff {
+		for _, a := range actions {
+			groups = append(groups, &Group{Key: a.EventID, Actions: []*action.Action{a}})
+		}
+		return groups
+	}
+
+	index := make(map[string]*Group)
+	var order []*Group
+	for _, a := range actions {
+		key := groupKey(a, mode)
+		g, ok := index[key]
+		if !ok {
+			g = &Group{Key: key}
+			index[key] = g
+			order = append(order, g)
+		}
+		g.Actions = append(g.Actions, a)
+	}
+
+	for _, g := range order {
+		if len(g.Actions) >= threshold {
+			groups = append(groups, g)
+			continue
+		}
+		for _, a := range g.Actions {
+			groups = append(groups, &Group{Key: a.EventID, Actions: []*action.Action{a}})
+		}
+	}
+	return groups
+}
+
+// Flatten returns every action contained in groups.
+func Flatten(groups []*Group) []*action.Action {
+	var actions []*action.Action
+	for _, g := range groups {
+		actions = append(actions, g.Actions...)
+	}
+	return actions
+}
+
+func groupKey(a *action.Action, mode Mode) string {
+	if mode == ModeFile {
+		return a.S3URI
+	}
+	return a.AccountID + "/" + a.UserName
+}
+
+// IsDigest reports whether the group summarizes more than one action.
+func (g *Group) IsDigest() bool {
+	return len(g.Actions) > 1
+}
+
+// First returns the first action added to the group.
+func (g *Group) First() *action.Action {
+	if len(g.Actions) == 0 {
+		return nil
+	}
+	return g.Actions[0]
+}
+
+// Counts tallies event names, most frequent first.
+func (g *Group) Counts() []Count {
+	tally := make(map[string]int)
+	for _, a := range g.Actions {
+		tally[a.EventName]++
+	}
+
+	counts := make([]Count, 0, len(tally))
+	for name, n := range tally {
+		counts = append(counts, Count{EventName: name, Count: n})
+	}
+	sort.Slice(counts, func(i, j int) bool {
+		if counts[i].Count != counts[j].Count {
+			return counts[i].Count > counts[j].Count
+		}
+		return counts[i].EventName < counts[j].EventName
+	})
+	return counts
+}
+
+// Errors is the number of actions that returned an errorCode.
+func (g *Group) Errors() int {
+	var n int
+	for _, a := range g.Actions {
+		if a.ErrorCode != "" {
+			n++
+		}
+	}
+	return n
+}
+
+// TimeRange returns the earliest and latest event times in the group.
+func (g *Group) TimeRange() (time.Time, time.Time) {
+	var start, end time.Time
+	for _, a := range g.Actions {
+		if a.EventTime.IsZero() {
+			continue
+		}
+		if start.IsZero() || a.EventTime.Before(start) {
+			start = a.EventTime
+		}
+		if end.IsZero() || a.EventTime.After(end) {
+			end = a.EventTime
+		}
+	}
+	return start, end
+}
+
+// Users lists the distinct user names in the group in order of appearance.
+func (g *Group) Users() []string {
+	seen := make(map[string]bool)
+	var users []string
+	for _, a := range g.Actions {
+		if seen[a.UserName] {
+			continue
+		}
+		seen[a.UserName] = true
+		users = append(users, a.UserName)
+	}
+	return users
+}
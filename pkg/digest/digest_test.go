@@ -0,0 +1,82 @@
+package digest
+
+import (
+	"testing"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+)
+
+func testAction(id, account, user, name, errorCode string, minute int) *action.Action {
+	return &action.Action{
+		EventID:   id,
+		AccountID: account,
+		UserName:  user,
+		EventName: name,
+		ErrorCode: errorCode,
+		EventTime: time.Date(2021, 5, 14, 19, minute, 0, 0, time.UTC),
+		S3URI:     "s3://bucket/" + account,
+	}
+}
+
+func TestBuildUserMode(t *testing.T) {
+	actions := []*action.Action{
+		testAction("1", "111", "alice", "CreateTags", "", 5),
+		testAction("2", "111", "bob", "PutUserPolicy", "", 6),
+		testAction("3", "111", "alice", "CreateTags", "AccessDenied", 1),
+		testAction("4", "111", "alice", "RunInstances", "", 9),
+	}
+
+	groups := Build(actions, ModeUser, 2)
+	if len(groups) != 2 {
+		t.Fatalf("expected 2 groups, got %d", len(groups))
+	}
+
+	g := groups[0]
+	if !g.IsDigest() || len(g.Actions) != 3 {
+		t.Fatalf("expected alice digest of 3 actions, got %+v", g)
+	}
+	if g.Errors() != 1 {
+		t.Fatalf("expected 1 error, got %d", g.Errors())
+	}
+
+	counts := g.Counts()
+	if counts[0].EventName != "CreateTags" || counts[0].Count != 2 {
+		t.Fatalf("unexpected counts %+v", counts)
+	}
+
+	start, end := g.TimeRange()
+	if start.Minute() != 1 || end.Minute() != 9 {
+		t.Fatalf("unexpected time range %v - %v", start, end)
+	}
+
+	if groups[1].IsDigest() || groups[1].First().UserName != "bob" {
+		t.Fatalf("expected bob to post individually, got %+v", groups[1])
+	}
+}
+
+func TestBuildThreshold(t *testing.T) {
+	actions := []*action.Action{
+		testAction("1", "111", "alice", "CreateTags", "", 1),
+		testAction("2", "222", "alice", "CreateTags", "", 2),
+	}
+
+	if groups := Build(actions, ModeFile, 3); len(groups) != 2 {
+		t.Fatalf("expected groups below threshold to split, got %d", len(groups))
+	}
+	if groups := Build(actions, ModeOff, 1); len(groups) != 2 {
+		t.Fatalf("expected no grouping when off, got %d", len(groups))
+	}
+}
+
+func TestParseMode(t *testing.T) {
+	for in, want := range map[string]Mode{"": ModeOff, "User": ModeUser, "file": ModeFile, "off": ModeOff} {
+		got, err := ParseMode(in)
+		if err != nil || got != want {
+			t.Fatalf("ParseMode(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseMode("account"); err == nil {
+		t.Fatalf("expected error for unknown mode")
+	}
+}
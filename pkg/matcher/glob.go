@@ -0,0 +1,25 @@
+package matcher
+
+import "path"
+
+// Glob reports whether s matches any of the glob patterns, or true when
+// there are none so that an unset rule field places no constraint. Patterns
+// use path.Match syntax, in which "*" matches any run of characters except
+// "/". That matters for ARNs: "arn:aws:iam::*:role/*" matches
+// "arn:aws:iam::123456789012:role/Admin" but not a role with a path such as
+// "role/ops/Admin", which needs "role/*/*" or the path spelled out. Malformed
+// patterns match nothing.
+//
+// Rule fields across the configuration match with Glob: every non-empty
+// field must match, and within a field any entry may.
+func Glob(patterns []string, s string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, p := range patterns {
+		if ok, _ := path.Match(p, s); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,123 @@
+// Package matcher matches strings against a fixed set of exact names,
+// prefixes, suffixes and regular expressions. Everything is compiled when the
+// set is built so matching a record does no parsing or allocation. Glob
+// matches the patterns of configured rules, which are only known at runtime.
+package matcher
+
+import "regexp"
+
+// Set is a compiled group of patterns. A string matches the set when it
+// matches any one of them. Sets are built once, typically into package
+// variables, and are safe for concurrent use once built.
+type Set struct {
+	exact    map[string]bool
+	prefixes *trie
+	suffixes *trie
+	regexps  []*regexp.Regexp
+}
+
+// New returns an empty set.
+func New() *Set {
+	return &Set{
+		exact:    make(map[string]bool),
+		prefixes: &trie{},
+		suffixes: &trie{},
+	}
+}
+
+// Exact adds names that must match in full.
+func (s *Set) Exact(names ...string) *Set {
+	for _, n := range names {
+		s.exact[n] = true
+	}
+	return s
+}
+
+// Prefix adds prefixes.
+func (s *Set) Prefix(prefixes ...string) *Set {
+	for _, p := range prefixes {
+		s.prefixes.insert(p, false)
+	}
+	return s
+}
+
+// Suffix adds suffixes.
+func (s *Set) Suffix(suffixes ...string) *Set {
+	for _, p := range suffixes {
+		s.suffixes.insert(p, true)
+	}
+	return s
+}
+
+// Regexp adds regular expressions. It panics if one does not compile, like
+// regexp.MustCompile, since sets are built from constants at startup.
+func (s *Set) Regexp(patterns ...string) *Set {
+	for _, p := range patterns {
+		s.regexps = append(s.regexps, regexp.MustCompile(p))
+	}
+	return s
+}
+
+// Match reports whether v matches any pattern in the set. The cheap checks
+// run first, so regular expressions are only evaluated as a last resort.
+func (s *Set) Match(v string) bool {
+	if s.exact[v] || s.prefixes.match(v, false) || s.suffixes.match(v, true) {
+		return true
+	}
+	for _, re := range s.regexps {
+		if re.MatchString(v) {
+			return true
+		}
+	}
+	return false
+}
+
+// trie is a byte trie. Suffixes are stored reversed so both kinds of lookup
+// walk from the root.
+type trie struct {
+	children map[byte]*trie
+	terminal bool
+}
+
+func (t *trie) insert(s string, reverse bool) {
+	node := t
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if reverse {
+			c = s[len(s)-1-i]
+		}
+		if node.children == nil {
+			node.children = make(map[byte]*trie)
+		}
+		next, ok := node.children[c]
+		if !ok {
+			next = &trie{}
+			node.children[c] = next
+		}
+		node = next
+	}
+	node.terminal = true
+}
+
+// match reports whether any string in the trie is a prefix of v, or a
+// suffix when reverse is set.
+func (t *trie) match(v string, reverse bool) bool {
+	node := t
+	for i := 0; ; i++ {
+		if node.terminal {
+			return true
+		}
+		if i == len(v) {
+			return false
+		}
+		c := v[i]
+		if reverse {
+			c = v[len(v)-1-i]
+		}
+		next, ok := node.children[c]
+		if !ok {
+			return false
+		}
+		node = next
+	}
+}
@@ -0,0 +1,73 @@
+package matcher
+
+import "testing"
+
+func TestSet(t *testing.T) {
+	s := New().
+		Exact("Decrypt", "startEnvironment").
+		Prefix("BatchGet", "Search", "StartQuery").
+		Suffix("VirtualMFADevice").
+		Regexp("console.*.amazonaws.com")
+
+	cases := map[string]bool{
+		"Decrypt":                   true,
+		"DecryptAll":                false,
+		"startEnvironment":          true,
+		"StartEnvironment":          false,
+		"BatchGetItem":              true,
+		"BatchGet":                  true,
+		"Batch":                     false,
+		"SearchProducts":            true,
+		"StartQueryExecution":       true,
+		"StartQ":                    false,
+		"CreateVirtualMFADevice":    true,
+		"VirtualMFADevice":          true,
+		"VirtualMFADevices":         false,
+		"console.ec2.amazonaws.com": true,
+		"signin.amazonaws.com":      false,
+		"":                          false,
+	}
+	for v, want := range cases {
+		if got := s.Match(v); got != want {
+			t.Errorf("%q: got %v, want %v", v, got, want)
+		}
+	}
+
+	if New().Match("anything") {
+		t.Error("expected an empty set to match nothing")
+	}
+}
+
+func BenchmarkSet(b *testing.B) {
+	s := New().
+		Exact("Decrypt", "SetTaskStatus", "QueryObjects", "ValidatePolicy").
+		Prefix("StartQuery", "StopQuery", "CancelQuery", "BatchGet", "Search").
+		Suffix("VirtualMFADevice")
+	names := []string{"RunInstances", "BatchGetItem", "Decrypt", "CreateVirtualMFADevice", "PutUserPolicy"}
+
+	for i := 0; i < b.N; i++ {
+		s.Match(names[i%len(names)])
+	}
+}
+
+func TestGlob(t *testing.T) {
+	cases := []struct {
+		patterns []string
+		s        string
+		want     bool
+	}{
+		{nil, "anything", true},
+		{[]string{"ec2.*", "iam.*"}, "iam.amazonaws.com", true},
+		{[]string{"ec2.*"}, "s3.amazonaws.com", false},
+		{[]string{"arn:aws:iam::*:role/*"}, "arn:aws:iam::123456789012:role/Admin", true},
+		{[]string{"arn:aws:iam::*:role/*"}, "arn:aws:iam::123456789012:role/ops/Admin", false},
+		{[]string{"arn:aws:iam::*:role/*/Admin"}, "arn:aws:iam::123456789012:role/ops/Admin", true},
+		{[]string{"[", "Admin"}, "Admin", true},
+		{[]string{"["}, "[", false},
+	}
+	for _, c := range cases {
+		if got := Glob(c.patterns, c.s); got != c.want {
+			t.Errorf("Glob(%q, %q) = %v, want %v", c.patterns, c.s, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package notify
+
+import (
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/session"
+)
+
+// awsConfig applies the destination's region and endpoint overrides. The
+// endpoint is mostly useful for pointing at a local fake during testing.
+func awsConfig(dest Destination) *aws.Config {
+	cfg := aws.NewConfig()
+	if dest.Region != "" {
+		cfg = cfg.WithRegion(dest.Region)
+	}
+	if dest.Endpoint != "" {
+		cfg = cfg.WithEndpoint(dest.Endpoint)
+	}
+	return cfg
+}
+
+func awsSession() *session.Session {
+	return session.Must(session.NewSession())
+}
+
+// attributes are the values stream consumers filter on.
+func attributes(a *action.Action) map[string]string {
+	attrs := map[string]string{
+		"account":      a.AccountID,
+		"event_source": a.EventSource,
+		"event_name":   a.EventName,
+		"severity":     a.Severity,
+	}
+	// SNS and SQS reject empty attribute values
+	for k, v := range attrs {
+		if v == "" {
+			delete(attrs, k)
+		}
+	}
+	return attrs
+}
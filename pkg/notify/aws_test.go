@@ -0,0 +1,241 @@
+package notify
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+)
+
+// fakeAWS is a minimal stand-in for the AWS query protocol endpoints.
+type fakeAWS struct {
+	mu       sync.Mutex
+	requests []url.Values
+
+	// reject fails batch entries whose body contains it
+	reject string
+}
+
+func (f *fakeAWS) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	f.mu.Lock()
+	f.requests = append(f.requests, r.PostForm)
+	f.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/xml")
+	switch r.PostForm.Get("Action") {
+	case "Publish":
+		fmt.Fprint(w, `<PublishResponse><PublishResult><MessageId>1</MessageId></PublishResult><ResponseMetadata><RequestId>r</RequestId></ResponseMetadata></PublishResponse>`)
+	case "SendMessageBatch":
+		var entries strings.Builder
+		for i := 1; r.PostForm.Get(fmt.Sprintf("SendMessageBatchRequestEntry.%d.Id", i)) != ""; i++ {
+			prefix := fmt.Sprintf("SendMessageBatchRequestEntry.%d.", i)
+			body := r.PostForm.Get(prefix + "MessageBody")
+			if f.reject != "" && strings.Contains(body, f.reject) {
+				fmt.Fprintf(&entries, `<BatchResultErrorEntry><Id>%s</Id><Code>InternalError</Code><SenderFault>false</SenderFault></BatchResultErrorEntry>`, r.PostForm.Get(prefix+"Id"))
+				continue
+			}
+			sum := md5.Sum([]byte(body))
+			fmt.Fprintf(&entries, `<SendMessageBatchResultEntry><Id>%s</Id><MessageId>m%d</MessageId><MD5OfMessageBody>%s</MD5OfMessageBody></SendMessageBatchResultEntry>`,
+				r.PostForm.Get(prefix+"Id"), i, hex.EncodeToString(sum[:]))
+		}
+		fmt.Fprintf(w, `<SendMessageBatchResponse><SendMessageBatchResult>%s</SendMessageBatchResult><ResponseMetadata><RequestId>r</RequestId></ResponseMetadata></SendMessageBatchResponse>`, entries.String())
+	default:
+		w.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+func testGroups(n int) []*digest.Group {
+	var actions []*action.Action
+	for i := 0; i < n; i++ {
+		actions = append(actions, &action.Action{
+			EventID:     fmt.Sprintf("event-%d", i),
+			EventName:   "CreateTags",
+			EventSource: "ec2.amazonaws.com",
+			AccountID:   "123456789012",
+			Severity:    "low",
+		})
+	}
+	return digest.Build(actions, digest.ModeUser, 2)
+}
+
+func withFakeAWS(t *testing.T) (*fakeAWS, string) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "test")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "test")
+	fake := &fakeAWS{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+	return fake, srv.URL
+}
+
+func TestSNSNotify(t *testing.T) {
+	fake, endpoint := withFakeAWS(t)
+
+	n, err := New("stream", Destination{
+		Type:     "sns",
+		Target:   "arn:aws:sns:us-east-1:123456789012:console-actions",
+		Region:   "us-east-1",
+		Endpoint: endpoint,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(testGroups(3)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.requests) != 3 {
+		t.Fatalf("expected 3 publishes, got %d", len(fake.requests))
+	}
+
+	req := fake.requests[0]
+	var got action.Action
+	if err := json.Unmarshal([]byte(req.Get("Message")), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.EventID != "event-0" {
+		t.Fatalf("unexpected message %+v", got)
+	}
+
+	attrs := make(map[string]string)
+	for i := 1; req.Get(fmt.Sprintf("MessageAttributes.entry.%d.Name", i)) != ""; i++ {
+		attrs[req.Get(fmt.Sprintf("MessageAttributes.entry.%d.Name", i))] = req.Get(fmt.Sprintf("MessageAttributes.entry.%d.Value.StringValue", i))
+	}
+	if attrs["account"] != "123456789012" || attrs["event_name"] != "CreateTags" || attrs["severity"] != "low" {
+		t.Fatalf("unexpected attributes %v", attrs)
+	}
+}
+
+func TestSQSNotify(t *testing.T) {
+	fake, endpoint := withFakeAWS(t)
+
+	n, err := New("queue", Destination{
+		Type:     "sqs",
+		URL:      endpoint + "/123456789012/console-actions",
+		Region:   "us-east-1",
+		Endpoint: endpoint,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := n.Notify(testGroups(12)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.requests) != 2 {
+		t.Fatalf("expected 2 batches, got %d", len(fake.requests))
+	}
+	if got := fake.requests[1].Get("SendMessageBatchRequestEntry.2.Id"); got != "11" {
+		t.Fatalf("unexpected entry id %q", got)
+	}
+	if got := fake.requests[0].Get("SendMessageBatchRequestEntry.1.MessageAttribute.1.Value.StringValue"); got == "" {
+		t.Fatalf("expected message attributes on entries")
+	}
+}
+
+func TestSQSPartialFailure(t *testing.T) {
+	fake, endpoint := withFakeAWS(t)
+	fake.reject = `"event-11"`
+
+	n, err := New("queue", Destination{
+		Type:     "sqs",
+		URL:      endpoint + "/123456789012/console-actions",
+		Region:   "us-east-1",
+		Endpoint: endpoint,
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := testGroups(12)
+	err = n.Notify(groups)
+	var delivery *DeliveryError
+	if !errors.As(err, &delivery) || len(delivery.Failures) != 1 {
+		t.Fatalf("expected a single failed message, got %v", err)
+	}
+	f := delivery.Failures[0]
+	if len(f.Actions) != 1 || f.Actions[0].EventID != "event-11" || !strings.Contains(string(f.Payload), `"event-11"`) {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+	if got := Undelivered(groups, err); len(got) != 1 || got[0].EventID != "event-11" {
+		t.Fatalf("expected only event-11 to be undelivered, got %d", len(got))
+	}
+}
+
+func TestEventBridgeNotify(t *testing.T) {
+	withFakeAWS(t)
+
+	var mu sync.Mutex
+	var batches [][]map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var in struct {
+			Entries []map[string]interface{}
+		}
+		json.NewDecoder(r.Body).Decode(&in)
+
+		mu.Lock()
+		batches = append(batches, in.Entries)
+		first := len(batches) == 1
+		mu.Unlock()
+
+		// Reject event-1 on the first attempt only
+		var results []string
+		failed := 0
+		for _, e := range in.Entries {
+			if first && strings.Contains(e["Detail"].(string), `"event-1"`) {
+				results = append(results, `{"ErrorCode":"InternalFailure","ErrorMessage":"try again"}`)
+				failed++
+				continue
+			}
+			results = append(results, `{"EventId":"x"}`)
+		}
+		w.Header().Set("Content-Type", "application/x-amz-json-1.1")
+		fmt.Fprintf(w, `{"FailedEntryCount":%d,"Entries":[%s]}`, failed, strings.Join(results, ","))
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := New("bus", Destination{
+		Type:     "eventbridge",
+		Target:   "console-actions",
+		Region:   "us-east-1",
+		Endpoint: srv.URL,
+		Options:  map[string]string{"source": "example.console"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n.(*EventBridge).Backoff = 20 * time.Millisecond
+	start := time.Now()
+	if err := n.Notify(testGroups(11)); err != nil {
+		t.Fatal(err)
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected failed entries to be resubmitted after a backoff, took %v", elapsed)
+	}
+
+	if len(batches) != 3 {
+		t.Fatalf("expected 2 batches and 1 retry, got %d calls", len(batches))
+	}
+	if len(batches[0]) != 10 || len(batches[1]) != 1 || len(batches[2]) != 1 {
+		t.Fatalf("unexpected batch sizes %d, %d, %d", len(batches[0]), len(batches[1]), len(batches[2]))
+	}
+
+	entry := batches[0][0]
+	if entry["Source"] != "example.console" || entry["DetailType"] != "Console Action" || entry["EventBusName"] != "console-actions" {
+		t.Fatalf("unexpected entry %v", entry)
+	}
+}
@@ -0,0 +1,175 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/detect"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+)
+
+func chatServer(t *testing.T) (*[]map[string]interface{}, string) {
+	var mu sync.Mutex
+	var bodies []map[string]interface{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		var body map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&body)
+		bodies = append(bodies, body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	t.Cleanup(srv.Close)
+	return &bodies, srv.URL
+}
+
+func chatGroups() []*digest.Group {
+	return digest.Build([]*action.Action{{
+		EventID:           "event-0",
+		EventName:         "TerminateInstances",
+		EventSource:       "ec2.amazonaws.com",
+		AccountID:         "123456789012",
+		IdentityAccountID: "123456789012",
+		Region:            "us-east-1",
+		UserName:          "alice",
+		ErrorCode:         "AccessDenied",
+	}}, digest.ModeOff, digest.DefaultThreshold)
+}
+
+var chatAccounts = account.Directory{"123456789012": {Name: "production"}}
+
+func TestDiscordNotify(t *testing.T) {
+	bodies, url := chatServer(t)
+	n, err := New("discord", Destination{Type: "discord", URL: url}, chatAccounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(chatGroups()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*bodies) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(*bodies))
+	}
+	b, _ := json.Marshal((*bodies)[0])
+	for _, want := range []string{"TerminateInstances", "production", "ec2.amazonaws.com", "alice", "AccessDenied", "console.aws.amazon.com/cloudtrail"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("discord message missing %q: %s", want, b)
+		}
+	}
+}
+
+func TestGoogleChatNotify(t *testing.T) {
+	os.Setenv("SLACK_NAME_123456789012", "prod override")
+	defer os.Unsetenv("SLACK_NAME_123456789012")
+
+	bodies, url := chatServer(t)
+	n, err := New("chat", Destination{Type: "googlechat", URL: url}, chatAccounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(chatGroups()); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*bodies) != 1 {
+		t.Fatalf("expected 1 message, got %d", len(*bodies))
+	}
+	b, _ := json.Marshal((*bodies)[0])
+	for _, want := range []string{"cardsV2", "TerminateInstances", "prod override", "ec2.amazonaws.com", "alice", "AccessDenied", "console.aws.amazon.com/cloudtrail"} {
+		if !strings.Contains(string(b), want) {
+			t.Errorf("google chat message missing %q: %s", want, b)
+		}
+	}
+}
+
+func TestGoogleChatEscapesHTML(t *testing.T) {
+	c := &GoogleChat{Accounts: chatAccounts}
+	a := chatGroups()[0].First()
+	a.UserName = "<b>eve</b>"
+	a.ErrorCode = "Denied&<i>"
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	enc.Encode(c.eventMessage(a))
+	for _, want := range []string{`"text":"&lt;b&gt;eve&lt;/b&gt;"`, `<font color=\"#d13212\">Denied&amp;&lt;i&gt;</font>`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("google chat message missing %q: %s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "<i>") {
+		t.Errorf("expected record fields in widgets to be escaped: %s", buf.String())
+	}
+}
+
+func TestGoogleChatDigestSeverity(t *testing.T) {
+	var actions []*action.Action
+	for i := 0; i < 3; i++ {
+		a := *chatGroups()[0].First()
+		a.Severity = "high"
+		actions = append(actions, &a)
+	}
+	groups := digest.Build(actions, digest.ModeUser, 2)
+	if len(groups) != 1 || !groups[0].IsDigest() {
+		t.Fatalf("expected a single digest, got %d groups", len(groups))
+	}
+
+	c := &GoogleChat{Accounts: chatAccounts}
+	b, _ := json.Marshal(c.digestMessage(groups[0]))
+	if !strings.Contains(string(b), `"topLabel":"Severity"`) || !strings.Contains(string(b), "high") {
+		t.Errorf("expected the digest to show its severity: %s", b)
+	}
+}
+
+func TestSlackSeverity(t *testing.T) {
+	s := &Slack{Channel: "#alerts", Accounts: chatAccounts}
+	a := chatGroups()[0].First()
+
+	var body map[string]interface{}
+	json.Unmarshal(s.eventBody(a), &body)
+	if _, ok := body["blocks"]; !ok || body["attachments"] != nil {
+		t.Errorf("expected unscored events to use top level blocks: %v", body)
+	}
+
+	a.Severity = "critical"
+	b := s.eventBody(a)
+	json.Unmarshal(b, &body)
+	attachments, _ := body["attachments"].([]interface{})
+	if len(attachments) != 1 || attachments[0].(map[string]interface{})["color"] != "#b60205" {
+		t.Fatalf("expected a colored attachment: %s", b)
+	}
+	if !strings.Contains(string(b), ":rotating_light: *TerminateInstances*") {
+		t.Errorf("expected the severity emoji: %s", b)
+	}
+}
+
+func TestRootShowsOrigin(t *testing.T) {
+	a := chatGroups()[0].First()
+	a.SourceIP = "203.0.113.7"
+	a.UserAgent = "Mozilla/5.0"
+	a.Tags = []string{detect.RootTag}
+
+	s := &Slack{Channel: "#alerts", Accounts: chatAccounts}
+	d := &Discord{Accounts: chatAccounts}
+	c := &GoogleChat{Accounts: chatAccounts}
+	slack := s.eventBody(a)
+	discord, _ := json.Marshal(d.eventEmbed(a))
+	chat, _ := json.Marshal(c.eventMessage(a))
+
+	for name, b := range map[string][]byte{"slack": slack, "discord": discord, "googlechat": chat} {
+		for _, want := range []string{"203.0.113.7", "Mozilla/5.0"} {
+			if !strings.Contains(string(b), want) {
+				t.Errorf("%s message missing %q: %s", name, want, b)
+			}
+		}
+	}
+}
@@ -0,0 +1,209 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+)
+
+const (
+	defaultDatadogSite      = "datadoghq.com"
+	defaultDatadogBatchSize = 500
+	datadogSource           = "cloudtrail-console-actions"
+)
+
+// Datadog posts actions as Events, one request per action, or as logs
+// through the HTTP intake in batches.
+type Datadog struct {
+	APIKey    string
+	Site      string
+	Logs      bool
+	BatchSize int
+	Format    string
+
+	// URL overrides the site's API and intake hosts, mostly for testing.
+	URL string
+
+	poster httpPoster
+	name   string
+}
+
+func newDatadog(name string, dest Destination) (*Datadog, error) {
+	apiKey := getOption(dest, "api_key", "")
+	if apiKey == "" {
+		return nil, fmt.Errorf("datadog destination requires options.api_key")
+	}
+
+	d := &Datadog{
+		APIKey:    apiKey,
+		Site:      getOption(dest, "site", defaultDatadogSite),
+		BatchSize: defaultDatadogBatchSize,
+		Format:    dest.Format,
+		URL:       strings.TrimSuffix(dest.URL, "/"),
+		poster:    newHTTPPoster(),
+		name:      name,
+	}
+	switch mode := getOption(dest, "mode", "events"); mode {
+	case "events":
+	case "logs":
+		d.Logs = true
+	default:
+		return nil, fmt.Errorf("unknown datadog mode %q", mode)
+	}
+	if v, err := strconv.Atoi(getOption(dest, "batch_size", "")); err == nil && v > 0 {
+		d.BatchSize = v
+	}
+	return d, nil
+}
+
+func (d *Datadog) Name() string {
+	return d.name
+}
+
+func (d *Datadog) Notify(groups []*digest.Group) error {
+	actions := digest.Flatten(groups)
+	if d.Logs {
+		return d.sendLogs(actions)
+	}
+	return d.sendEvents(actions)
+}
+
+// Resend posts a previously rendered event, or batch of logs in logs mode.
+func (d *Datadog) Resend(payload []byte) error {
+	_, err := d.poster.post(d.endpoint(), d.headers(), payload)
+	return err
+}
+
+// endpoint is the URL events or logs are posted to.
+func (d *Datadog) endpoint() string {
+	if d.Logs {
+		if d.URL != "" {
+			return d.URL + "/api/v2/logs"
+		}
+		return "https://http-intake.logs." + d.Site + "/api/v2/logs"
+	}
+	if d.URL != "" {
+		return d.URL + "/api/v1/events"
+	}
+	return "https://api." + d.Site + "/api/v1/events"
+}
+
+func (d *Datadog) sendEvents(actions []*action.Action) error {
+	var failures []Failure
+	for _, a := range actions {
+		alertType := "info"
+		if a.ErrorCode != "" {
+			alertType = "error"
+		}
+
+		text := fmt.Sprintf("%s called %s on %s in %s\n%s", a.UserName, a.EventName, a.EventSource, a.AccountID, a.ConsoleURL())
+		if a.ErrorCode != "" {
+			text += "\nError: " + a.ErrorCode
+		}
+
+		event := map[string]interface{}{
+			"title":            fmt.Sprintf("%s - %s", a.EventName, a.UserName),
+			"text":             text,
+			"tags":             datadogTags(a),
+			"alert_type":       alertType,
+			"source_type_name": "amazon web services",
+			"aggregation_key":  a.AccountID + "/" + a.UserName,
+			"host":             a.AccountID,
+		}
+		if !a.EventTime.IsZero() {
+			event["date_happened"] = a.EventTime.Unix()
+		}
+
+		body, err := json.Marshal(event)
+		if err != nil {
+			failures = append(failures, Failure{Actions: []*action.Action{a}, Err: err})
+			continue
+		}
+		if err := d.Resend(body); err != nil {
+			failures = append(failures, Failure{Payload: body, Actions: []*action.Action{a}, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "datadog", Total: len(actions), Failures: failures}
+	}
+	return nil
+}
+
+func (d *Datadog) sendLogs(actions []*action.Action) error {
+	var failures []Failure
+	var batches int
+	for start := 0; start < len(actions); start += d.BatchSize {
+		end := start + d.BatchSize
+		if end > len(actions) {
+			end = len(actions)
+		}
+		batches++
+
+		var entries []map[string]interface{}
+		var sent []*action.Action
+		for _, a := range actions[start:end] {
+			message, err := marshalAction(a, d.Format)
+			if err != nil {
+				failures = append(failures, Failure{Actions: []*action.Action{a}, Err: err})
+				continue
+			}
+			entries = append(entries, map[string]interface{}{
+				"ddsource": datadogSource,
+				"ddtags":   strings.Join(datadogTags(a), ","),
+				"hostname": a.AccountID,
+				"service":  a.EventSource,
+				"message":  message,
+			})
+			sent = append(sent, a)
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		body, err := json.Marshal(entries)
+		if err != nil {
+			failures = append(failures, Failure{Actions: sent, Err: err})
+			continue
+		}
+		if err := d.Resend(body); err != nil {
+			failures = append(failures, Failure{Payload: body, Actions: sent, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "datadog", Total: batches, Failures: failures}
+	}
+	return nil
+}
+
+func (d *Datadog) headers() map[string]string {
+	return map[string]string{
+		"Content-Type": "application/json",
+		"DD-API-KEY":   d.APIKey,
+	}
+}
+
+// datadogTags returns the tags dashboards filter console actions on.
+func datadogTags(a *action.Action) []string {
+	var tags []string
+	for _, t := range []struct{ key, value string }{
+		{"account", a.AccountID},
+		{"service", a.EventSource},
+		{"user", a.UserName},
+		{"region", a.Region},
+		{"event_name", a.EventName},
+		{"severity", a.Severity},
+		{"error_code", a.ErrorCode},
+		{"client", a.Client},
+	} {
+		if t.value != "" {
+			tags = append(tags, t.key+":"+t.value)
+		}
+	}
+	return tags
+}
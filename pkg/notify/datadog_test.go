@@ -0,0 +1,133 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type fakeDatadog struct {
+	mu      sync.Mutex
+	calls   int
+	events  []map[string]interface{}
+	batches [][]map[string]interface{}
+}
+
+func (f *fakeDatadog) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if r.Header.Get("DD-API-KEY") != "secret" {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	f.calls++
+	if f.calls == 1 {
+		w.WriteHeader(http.StatusTooManyRequests)
+		return
+	}
+
+	switch r.URL.Path {
+	case "/api/v1/events":
+		var e map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&e)
+		f.events = append(f.events, e)
+		w.WriteHeader(http.StatusAccepted)
+	case "/api/v2/logs":
+		var batch []map[string]interface{}
+		json.NewDecoder(r.Body).Decode(&batch)
+		f.batches = append(f.batches, batch)
+		w.WriteHeader(http.StatusAccepted)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func newTestDatadog(t *testing.T, options map[string]string) (*Datadog, *fakeDatadog) {
+	fake := &fakeDatadog{}
+	srv := httptest.NewServer(fake)
+	t.Cleanup(srv.Close)
+
+	options["api_key"] = "secret"
+	n, err := New("dd", Destination{Type: "datadog", URL: srv.URL, Options: options}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	d := n.(*Datadog)
+	d.poster.Backoff = time.Millisecond
+	return d, fake
+}
+
+func TestDatadogEvents(t *testing.T) {
+	d, fake := newTestDatadog(t, map[string]string{})
+
+	groups := testGroups(2)
+	groups[0].Actions[1].ErrorCode = "AccessDenied"
+	groups[0].Actions[1].UserName = "alice"
+	groups[0].Actions[1].Region = "us-east-1"
+
+	if err := d.Notify(groups); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.events) != 2 {
+		t.Fatalf("expected 2 events, got %d", len(fake.events))
+	}
+	e := fake.events[1]
+	if e["alert_type"] != "error" {
+		t.Errorf("expected error alert for failed call, got %v", e["alert_type"])
+	}
+	var tags []string
+	for _, tag := range e["tags"].([]interface{}) {
+		tags = append(tags, tag.(string))
+	}
+	joined := strings.Join(tags, ",")
+	for _, want := range []string{"account:123456789012", "service:ec2.amazonaws.com", "user:alice", "region:us-east-1"} {
+		if !strings.Contains(joined, want) {
+			t.Errorf("missing tag %s in %s", want, joined)
+		}
+	}
+}
+
+func TestDatadogLogs(t *testing.T) {
+	d, fake := newTestDatadog(t, map[string]string{"mode": "logs", "batch_size": "2"})
+
+	if err := d.Notify(testGroups(5)); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(fake.batches) != 3 {
+		t.Fatalf("expected 3 batches, got %d", len(fake.batches))
+	}
+	entry := fake.batches[0][0]
+	if entry["ddsource"] != datadogSource || entry["service"] != "ec2.amazonaws.com" {
+		t.Errorf("unexpected log entry %v", entry)
+	}
+	if !strings.Contains(entry["ddtags"].(string), "account:123456789012") {
+		t.Errorf("unexpected ddtags %v", entry["ddtags"])
+	}
+	var msg map[string]interface{}
+	if err := json.Unmarshal([]byte(entry["message"].(string)), &msg); err != nil || msg["event_id"] != "event-0" {
+		t.Errorf("unexpected message %v: %v", entry["message"], err)
+	}
+}
+
+func TestDatadogSite(t *testing.T) {
+	_, err := New("dd", Destination{Type: "datadog", Options: map[string]string{"mode": "metrics", "api_key": "x"}}, nil)
+	if err == nil {
+		t.Error("expected an error for an unknown mode")
+	}
+
+	n, err := New("dd", Destination{Type: "datadog", Options: map[string]string{"site": "datadoghq.eu", "api_key": "x"}}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := n.(*Datadog); d.Site != "datadoghq.eu" || d.URL != "" {
+		t.Errorf("unexpected site %q", d.Site)
+	}
+}
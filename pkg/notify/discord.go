@@ -0,0 +1,169 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/detect"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/useragent"
+)
+
+const (
+	defaultDiscordUsername = "CloudTrail Console Actions"
+
+	discordColorOK    = 0xff9900
+	discordColorError = 0xd13212
+)
+
+// Discord posts actions to a Discord webhook as embeds carrying the same
+// information as the Slack blocks.
+type Discord struct {
+	Webhook  string
+	Username string
+	Accounts account.Directory
+
+	poster httpPoster
+	name   string
+}
+
+func newDiscord(name string, dest Destination, accounts account.Directory) (*Discord, error) {
+	if dest.URL == "" {
+		return nil, fmt.Errorf("discord destination requires a url")
+	}
+	return &Discord{
+		Webhook:  dest.URL,
+		Username: getOption(dest, "username", defaultDiscordUsername),
+		Accounts: accounts,
+		poster:   newHTTPPoster(),
+		name:     name,
+	}, nil
+}
+
+func (d *Discord) Name() string {
+	return d.name
+}
+
+// Resend posts a previously rendered message body.
+func (d *Discord) Resend(payload []byte) error {
+	headers := map[string]string{"Content-Type": "application/json"}
+	_, err := d.poster.post(d.Webhook, headers, payload)
+	return err
+}
+
+func (d *Discord) Notify(groups []*digest.Group) error {
+	var failures []Failure
+	for _, group := range groups {
+		var embed map[string]interface{}
+		if group.IsDigest() {
+			embed = d.digestEmbed(group)
+		} else {
+			embed = d.eventEmbed(group.First())
+		}
+
+		body, err := json.Marshal(map[string]interface{}{
+			"username": d.Username,
+			"embeds":   []interface{}{embed},
+		})
+		if err != nil {
+			failures = append(failures, Failure{Actions: group.Actions, Err: err})
+			continue
+		}
+		if err := d.Resend(body); err != nil {
+			failures = append(failures, Failure{Payload: body, Actions: group.Actions, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "discord", Total: len(groups), Failures: failures}
+	}
+	return nil
+}
+
+func (d *Discord) eventEmbed(a *action.Action) map[string]interface{} {
+	fields := []map[string]interface{}{
+		{"name": "Account", "value": d.Accounts.Name(a.AccountID, a.IdentityAccountID), "inline": true},
+		{"name": "User", "value": fieldValue(a.UserName), "inline": true},
+		{"name": "Source", "value": fieldValue(a.EventSource), "inline": true},
+	}
+	if a.Via != "" && a.Client != useragent.Unknown {
+		fields = append(fields, map[string]interface{}{"name": "Via", "value": a.Via, "inline": true})
+	}
+	color := discordColorOK
+	if a.ErrorCode != "" {
+		fields = append(fields, map[string]interface{}{"name": "Error", "value": "`" + a.ErrorCode + "`", "inline": true})
+		color = discordColorError
+	}
+	if len(a.Tags) > 0 {
+		fields = append(fields, map[string]interface{}{"name": "Tags", "value": strings.Join(a.Tags, ", "), "inline": true})
+	}
+	if a.HasTag(detect.RootTag) {
+		fields = append(fields,
+			map[string]interface{}{"name": "Source IP", "value": fieldValue(a.SourceIP), "inline": true},
+			map[string]interface{}{"name": "User Agent", "value": fieldValue(a.UserAgent)},
+		)
+	}
+	if style, ok := severityStyles[a.Severity]; ok {
+		fields = append(fields, map[string]interface{}{"name": "Severity", "value": a.Severity, "inline": true})
+		color = style.Color
+	}
+
+	embed := map[string]interface{}{
+		"title":  a.EventName,
+		"url":    a.ConsoleURL(),
+		"color":  color,
+		"fields": fields,
+	}
+	if a.Detail != "" {
+		embed["description"] = a.Detail
+	}
+	if !a.EventTime.IsZero() {
+		embed["footer"] = map[string]string{"text": a.EventTimeString()}
+		embed["timestamp"] = a.EventTime.UTC().Format("2006-01-02T15:04:05Z")
+	}
+	return embed
+}
+
+func (d *Discord) digestEmbed(g *digest.Group) map[string]interface{} {
+	first := g.First()
+	users := strings.Join(g.Users(), ", ")
+
+	var lines []string
+	for _, c := range g.Counts() {
+		lines = append(lines, fmt.Sprintf("`%s` x%d", c.EventName, c.Count))
+	}
+
+	fields := []map[string]interface{}{
+		{"name": "Account", "value": d.Accounts.Name(first.AccountID, first.IdentityAccountID), "inline": true},
+		{"name": "Users", "value": fieldValue(users), "inline": true},
+		{"name": "Time", "value": timeRange(g), "inline": false},
+	}
+	color := discordColorOK
+	if n := g.Errors(); n > 0 {
+		fields = append(fields, map[string]interface{}{"name": "Errors", "value": fmt.Sprintf("%d", n), "inline": true})
+		color = discordColorError
+	}
+	if style, ok := severityStyles[severity.Max(g.Actions)]; ok {
+		color = style.Color
+	}
+
+	return map[string]interface{}{
+		"title":       fmt.Sprintf("%d console actions", len(g.Actions)),
+		"description": strings.Join(lines, "\n"),
+		"color":       color,
+		"fields":      fields,
+	}
+}
+
+// fieldValue substitutes a placeholder for empty values, which chat services
+// reject in embed and card fields.
+func fieldValue(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return v
+}
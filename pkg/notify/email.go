@@ -0,0 +1,295 @@
+package notify
+
+import (
+	"bytes"
+	"embed"
+	"fmt"
+	htmltemplate "html/template"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/smtp"
+	"net/textproto"
+	"net/url"
+	"strings"
+	texttemplate "text/template"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/ses"
+	"github.com/aws/aws-sdk-go/service/ses/sesiface"
+	log "github.com/sirupsen/logrus"
+)
+
+//go:embed templates
+var templates embed.FS
+
+const defaultEmailSubject = "{{ .Total }} console actions in {{ .AccountName }}"
+
+// Email sends one digest per account for each batch of actions, rendered
+// from an HTML and a plaintext template. Recipients come from the account's
+// emails, falling back to the destination's default list.
+type Email struct {
+	From     string
+	To       []string
+	Accounts account.Directory
+	Sender   MailSender
+
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+	name    string
+}
+
+// MailSender delivers a rendered email.
+type MailSender interface {
+	Send(from string, to []string, subject, text, html string) error
+}
+
+// EmailDigest is the data passed to the email templates.
+type EmailDigest struct {
+	AccountID   string
+	AccountName string
+	Total       int
+	Sessions    []EmailSession
+}
+
+// EmailSession is the set of actions a single user made.
+type EmailSession struct {
+	User    string
+	Start   string
+	End     string
+	Errors  int
+	Actions []*action.Action
+}
+
+func newEmail(name string, dest Destination, accounts account.Directory) (*Email, error) {
+	from := getOption(dest, "from", "")
+	if from == "" {
+		return nil, fmt.Errorf("%s destination requires options.from", dest.Type)
+	}
+
+	e := &Email{
+		From:     from,
+		Accounts: accounts,
+		name:     name,
+	}
+	if to := getOption(dest, "to", ""); to != "" {
+		for _, addr := range strings.Split(to, ",") {
+			e.To = append(e.To, strings.TrimSpace(addr))
+		}
+	}
+
+	var err error
+	e.subject, err = texttemplate.New("subject").Parse(getOption(dest, "subject", defaultEmailSubject))
+	if err != nil {
+		return nil, fmt.Errorf("subject template: %v", err)
+	}
+
+	text, err := readTemplate(dest, "text_template", "templates/email.txt")
+	if err != nil {
+		return nil, err
+	}
+	e.text, err = texttemplate.New("text").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("text template: %v", err)
+	}
+
+	html, err := readTemplate(dest, "html_template", "templates/email.html")
+	if err != nil {
+		return nil, err
+	}
+	e.html, err = htmltemplate.New("html").Parse(html)
+	if err != nil {
+		return nil, fmt.Errorf("html template: %v", err)
+	}
+
+	switch dest.Type {
+	case "ses":
+		e.Sender = &SESSender{
+			Client:           ses.New(awsSession(), awsConfig(dest)),
+			ConfigurationSet: getOption(dest, "configuration_set", ""),
+		}
+	case "smtp":
+		sender, err := newSMTPSender(dest.URL)
+		if err != nil {
+			return nil, err
+		}
+		e.Sender = sender
+	}
+
+	return e, nil
+}
+
+// readTemplate loads a template from the path in option or the embedded default.
+func readTemplate(dest Destination, option, fallback string) (string, error) {
+	if path := getOption(dest, option, ""); path != "" {
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("%s: %v", option, err)
+		}
+		return string(b), nil
+	}
+
+	b, err := templates.ReadFile(fallback)
+	return string(b), err
+}
+
+func (e *Email) Name() string {
+	return e.name
+}
+
+func (e *Email) Notify(groups []*digest.Group) error {
+	var order []string
+	byAccount := make(map[string][]*action.Action)
+	for _, a := range digest.Flatten(groups) {
+		if _, ok := byAccount[a.AccountID]; !ok {
+			order = append(order, a.AccountID)
+		}
+		byAccount[a.AccountID] = append(byAccount[a.AccountID], a)
+	}
+
+	// Emails have a subject and two bodies rather than a single payload, so
+	// failures only carry their actions and are rendered again on replay
+	var failures []Failure
+	for _, id := range order {
+		to := e.Accounts[id].Emails
+		if len(to) == 0 {
+			to = e.To
+		}
+		if len(to) == 0 {
+			log.Debugf("No email recipients for account %s", id)
+			continue
+		}
+
+		subject, text, html, err := e.Render(byAccount[id])
+		if err == nil {
+			err = e.Sender.Send(e.From, to, subject, text, html)
+		}
+		if err != nil {
+			failures = append(failures, Failure{Actions: byAccount[id], Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "email", Total: len(order), Failures: failures}
+	}
+	return nil
+}
+
+// Render produces the subject and bodies for a single account's actions.
+func (e *Email) Render(actions []*action.Action) (string, string, string, error) {
+	first := actions[0]
+	data := EmailDigest{
+		AccountID:   first.AccountID,
+		AccountName: e.Accounts.Name(first.AccountID, first.IdentityAccountID),
+		Total:       len(actions),
+	}
+
+	for _, g := range digest.Build(actions, digest.ModeUser, 1) {
+		start, end := g.TimeRange()
+		data.Sessions = append(data.Sessions, EmailSession{
+			User:    g.First().UserName,
+			Start:   start.UTC().Format(time.RFC3339),
+			End:     end.UTC().Format(time.RFC3339),
+			Errors:  g.Errors(),
+			Actions: g.Actions,
+		})
+	}
+
+	var subject, text, html bytes.Buffer
+	if err := e.subject.Execute(&subject, data); err != nil {
+		return "", "", "", err
+	}
+	if err := e.text.Execute(&text, data); err != nil {
+		return "", "", "", err
+	}
+	if err := e.html.Execute(&html, data); err != nil {
+		return "", "", "", err
+	}
+	return subject.String(), text.String(), html.String(), nil
+}
+
+// SESSender sends email through Amazon SES.
+type SESSender struct {
+	Client           sesiface.SESAPI
+	ConfigurationSet string
+}
+
+func (s *SESSender) Send(from string, to []string, subject, text, html string) error {
+	input := &ses.SendEmailInput{
+		Source:      aws.String(from),
+		Destination: &ses.Destination{ToAddresses: aws.StringSlice(to)},
+		Message: &ses.Message{
+			Subject: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(subject)},
+			Body: &ses.Body{
+				Text: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(text)},
+				Html: &ses.Content{Charset: aws.String("UTF-8"), Data: aws.String(html)},
+			},
+		},
+	}
+	if s.ConfigurationSet != "" {
+		input.ConfigurationSetName = aws.String(s.ConfigurationSet)
+	}
+
+	_, err := s.Client.SendEmail(input)
+	return err
+}
+
+// SMTPSender sends multipart email to an SMTP server, mostly for local testing.
+type SMTPSender struct {
+	Addr string
+	Auth smtp.Auth
+}
+
+// newSMTPSender parses smtp://[user:password@]host:port.
+func newSMTPSender(rawURL string) (*SMTPSender, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme != "smtp" || u.Host == "" {
+		return nil, fmt.Errorf("smtp destination requires a url like smtp://host:port")
+	}
+
+	s := &SMTPSender{Addr: u.Host}
+	if u.User != nil {
+		password, _ := u.User.Password()
+		s.Auth = smtp.PlainAuth("", u.User.Username(), password, u.Hostname())
+	}
+	return s, nil
+}
+
+func (s *SMTPSender) Send(from string, to []string, subject, text, html string) error {
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+
+	fmt.Fprintf(&body, "From: %s\r\n", from)
+	fmt.Fprintf(&body, "To: %s\r\n", strings.Join(to, ", "))
+	// Subjects carry user and account names from records, which may be
+	// non-ASCII or contain line breaks that would start another header
+	subject = strings.NewReplacer("\r", " ", "\n", " ").Replace(subject)
+	fmt.Fprintf(&body, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", subject))
+	fmt.Fprintf(&body, "MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&body, "Content-Type: multipart/alternative; boundary=%s\r\n\r\n", mw.Boundary())
+
+	for _, part := range []struct{ contentType, content string }{
+		{"text/plain; charset=UTF-8", text},
+		{"text/html; charset=UTF-8", html},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return err
+		}
+		qp := quotedprintable.NewWriter(w)
+		qp.Write([]byte(part.content))
+		qp.Close()
+	}
+	mw.Close()
+
+	return smtp.SendMail(s.Addr, s.Auth, from, to, body.Bytes())
+}
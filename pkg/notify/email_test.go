@@ -0,0 +1,126 @@
+package notify
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+)
+
+// fakeSMTP accepts messages on a local listener and records each DATA block.
+func fakeSMTP(t *testing.T) (string, chan string) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	messages := make(chan string, 10)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				r := bufio.NewReader(conn)
+				conn.Write([]byte("220 localhost ESMTP\r\n"))
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil {
+						return
+					}
+					switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+					case strings.HasPrefix(cmd, "EHLO"), strings.HasPrefix(cmd, "HELO"):
+						conn.Write([]byte("250 localhost\r\n"))
+					case cmd == "DATA":
+						conn.Write([]byte("354 go ahead\r\n"))
+						var data strings.Builder
+						for {
+							l, err := r.ReadString('\n')
+							if err != nil || l == ".\r\n" {
+								break
+							}
+							data.WriteString(l)
+						}
+						messages <- data.String()
+						conn.Write([]byte("250 ok\r\n"))
+					case cmd == "QUIT":
+						conn.Write([]byte("221 bye\r\n"))
+						return
+					default:
+						conn.Write([]byte("250 ok\r\n"))
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return ln.Addr().String(), messages
+}
+
+func TestEmailNotify(t *testing.T) {
+	addr, messages := fakeSMTP(t)
+
+	accounts := account.Directory{
+		"111111111111": {Name: "sandbox", Emails: []string{"sandbox-owner@example.com"}},
+	}
+	n, err := New("mail", Destination{
+		Type: "smtp",
+		URL:  "smtp://" + addr,
+		Options: map[string]string{
+			"from": "console-actions@example.com",
+			"to":   "cloud-team@example.com",
+		},
+	}, accounts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := time.Date(2021, 5, 14, 19, 3, 40, 0, time.UTC)
+	actions := []*action.Action{
+		{EventID: "1", AccountID: "111111111111", UserName: "alice", EventName: "CreateTags", EventSource: "ec2.amazonaws.com", EventTime: at},
+		{EventID: "2", AccountID: "222222222222", UserName: "bob", EventName: "PutUserPolicy", EventSource: "iam.amazonaws.com", EventTime: at},
+		{EventID: "3", AccountID: "111111111111", UserName: "alice", EventName: "<script>", EventSource: "ec2.amazonaws.com", EventTime: at, ErrorCode: "AccessDenied"},
+	}
+
+	if err := n.Notify(digest.Build(actions, digest.ModeOff, 0)); err != nil {
+		t.Fatal(err)
+	}
+
+	first := <-messages
+	second := <-messages
+
+	if !strings.Contains(first, "To: sandbox-owner@example.com") || !strings.Contains(first, "Subject: 2 console actions in sandbox") {
+		t.Fatalf("unexpected first email:\n%s", first)
+	}
+	if !strings.Contains(first, "text/plain") || !strings.Contains(first, "&lt;script&gt;") {
+		t.Fatalf("expected plaintext and escaped html parts:\n%s", first)
+	}
+	if !strings.Contains(second, "To: cloud-team@example.com") {
+		t.Fatalf("expected fallback recipients:\n%s", second)
+	}
+}
+
+func TestSMTPSubjectEncoding(t *testing.T) {
+	addr, messages := fakeSMTP(t)
+
+	s := &SMTPSender{Addr: addr}
+	if err := s.Send("console-actions@example.com", []string{"cloud-team@example.com"}, "3 console actions by Zoë\r\nBcc: mallory@example.com", "text", "<p>html</p>"); err != nil {
+		t.Fatal(err)
+	}
+
+	msg := <-messages
+	if !strings.Contains(msg, "Subject: =?utf-8?q?3_console_actions_by_Zo=C3=AB__Bcc:_mallory@example.com?=\r\n") {
+		t.Fatalf("expected an encoded single line subject:\n%s", msg)
+	}
+	if strings.Contains(msg, "\r\nBcc:") {
+		t.Fatalf("expected line breaks in the subject to be removed:\n%s", msg)
+	}
+}
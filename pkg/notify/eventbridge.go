@@ -0,0 +1,146 @@
+package notify
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/eventbridge"
+	"github.com/aws/aws-sdk-go/service/eventbridge/eventbridgeiface"
+)
+
+const (
+	// eventBridgeBatchSize is the maximum number of entries PutEvents accepts.
+	eventBridgeBatchSize = 10
+	// eventBridgeAttempts bounds how often failed entries are resubmitted.
+	eventBridgeAttempts = 3
+
+	defaultEventSource = "cloudtrail-console-actions"
+	defaultDetailType  = "Console Action"
+)
+
+// EventBridge puts every action onto an event bus.
+type EventBridge struct {
+	EventBus   string
+	Source     string
+	DetailType string
+	Client     eventbridgeiface.EventBridgeAPI
+	Format     string
+
+	// Backoff is the wait before the first resubmission of failed
+	// entries, doubling for each one after.
+	Backoff time.Duration
+
+	name string
+}
+
+func newEventBridge(name string, dest Destination) (*EventBridge, error) {
+	return &EventBridge{
+		EventBus:   getOption(dest, "event_bus", dest.Target),
+		Source:     getOption(dest, "source", defaultEventSource),
+		DetailType: getOption(dest, "detail_type", defaultDetailType),
+		Format:     dest.Format,
+		Client:     eventbridge.New(awsSession(), awsConfig(dest)),
+		Backoff:    defaultHTTPBackoff,
+		name:       name,
+	}, nil
+}
+
+func (e *EventBridge) Name() string {
+	return e.name
+}
+
+func (e *EventBridge) Notify(groups []*digest.Group) error {
+	actions := digest.Flatten(groups)
+
+	var failures []Failure
+	for start := 0; start < len(actions); start += eventBridgeBatchSize {
+		end := start + eventBridgeBatchSize
+		if end > len(actions) {
+			end = len(actions)
+		}
+
+		failures = append(failures, e.putBatch(actions[start:end])...)
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "eventbridge", Total: len(actions), Failures: failures}
+	}
+	return nil
+}
+
+// putBatch sends a batch, resubmitting only the entries that failed. It
+// returns a failure for each entry that never succeeded.
+func (e *EventBridge) putBatch(batch []*action.Action) []Failure {
+	// An action that cannot be encoded never will be, so it fails without
+	// holding up the rest of the batch
+	var unencodable []Failure
+	var pending []*action.Action
+	var entries []*eventbridge.PutEventsRequestEntry
+	for _, a := range batch {
+		entry, err := e.entry(a)
+		if err != nil {
+			unencodable = append(unencodable, Failure{Actions: []*action.Action{a}, Err: err})
+			continue
+		}
+		pending = append(pending, a)
+		entries = append(entries, entry)
+	}
+
+	var failures []Failure
+	for attempt := 0; attempt < eventBridgeAttempts && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(e.Backoff, attempt))
+		}
+		failures = nil
+		out, err := e.Client.PutEvents(&eventbridge.PutEventsInput{Entries: entries})
+		if err != nil {
+			for i, a := range pending {
+				failures = append(failures, entryFailure(a, entries[i], err))
+			}
+			continue
+		}
+
+		// Result entries are returned in request order.
+		var retry []*action.Action
+		var retryEntries []*eventbridge.PutEventsRequestEntry
+		for i, r := range out.Entries {
+			if r.ErrorCode == nil || i >= len(pending) {
+				continue
+			}
+			retry = append(retry, pending[i])
+			retryEntries = append(retryEntries, entries[i])
+			failures = append(failures, entryFailure(pending[i], entries[i],
+				fmt.Errorf("%s: %s", aws.StringValue(r.ErrorCode), aws.StringValue(r.ErrorMessage))))
+		}
+		pending, entries = retry, retryEntries
+	}
+
+	return append(unencodable, failures...)
+}
+
+func entryFailure(a *action.Action, entry *eventbridge.PutEventsRequestEntry, err error) Failure {
+	return Failure{Payload: []byte(aws.StringValue(entry.Detail)), Actions: []*action.Action{a}, Err: err}
+}
+
+func (e *EventBridge) entry(a *action.Action) (*eventbridge.PutEventsRequestEntry, error) {
+	detail, err := marshalAction(a, e.Format)
+	if err != nil {
+		return nil, err
+	}
+
+	entry := &eventbridge.PutEventsRequestEntry{
+		Source:     aws.String(e.Source),
+		DetailType: aws.String(e.DetailType),
+		Detail:     aws.String(detail),
+	}
+	if e.EventBus != "" {
+		entry.EventBusName = aws.String(e.EventBus)
+	}
+	if !a.EventTime.IsZero() {
+		entry.Time = aws.Time(a.EventTime)
+	}
+	return entry, nil
+}
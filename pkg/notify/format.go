@@ -0,0 +1,36 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/ocsf"
+)
+
+// Formats stream sinks can write actions in.
+const (
+	FormatNative = "native"
+	FormatOCSF   = "ocsf"
+)
+
+func validFormat(format string) error {
+	switch format {
+	case "", FormatNative, FormatOCSF:
+		return nil
+	}
+	return fmt.Errorf("unknown format %q", format)
+}
+
+// encode returns the representation of an action written by stream sinks.
+func encode(a *action.Action, format string) interface{} {
+	if format == FormatOCSF {
+		return ocsf.FromAction(a)
+	}
+	return a
+}
+
+func marshalAction(a *action.Action, format string) (string, error) {
+	b, err := json.Marshal(encode(a, format))
+	return string(b), err
+}
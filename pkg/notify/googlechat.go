@@ -0,0 +1,204 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/detect"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/useragent"
+)
+
+// GoogleChat posts actions to a Google Chat space webhook as cards carrying
+// the same information as the Slack blocks.
+type GoogleChat struct {
+	Webhook  string
+	Accounts account.Directory
+
+	poster httpPoster
+	name   string
+}
+
+func newGoogleChat(name string, dest Destination, accounts account.Directory) (*GoogleChat, error) {
+	if dest.URL == "" {
+		return nil, fmt.Errorf("googlechat destination requires a url")
+	}
+	return &GoogleChat{
+		Webhook:  dest.URL,
+		Accounts: accounts,
+		poster:   newHTTPPoster(),
+		name:     name,
+	}, nil
+}
+
+func (c *GoogleChat) Name() string {
+	return c.name
+}
+
+// Resend posts a previously rendered message body.
+func (c *GoogleChat) Resend(payload []byte) error {
+	headers := map[string]string{"Content-Type": "application/json; charset=UTF-8"}
+	_, err := c.poster.post(c.Webhook, headers, payload)
+	return err
+}
+
+func (c *GoogleChat) Notify(groups []*digest.Group) error {
+	var failures []Failure
+	for _, group := range groups {
+		var msg map[string]interface{}
+		if group.IsDigest() {
+			msg = c.digestMessage(group)
+		} else {
+			msg = c.eventMessage(group.First())
+		}
+
+		body, err := json.Marshal(msg)
+		if err != nil {
+			failures = append(failures, Failure{Actions: group.Actions, Err: err})
+			continue
+		}
+		if err := c.Resend(body); err != nil {
+			failures = append(failures, Failure{Payload: body, Actions: group.Actions, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "google chat", Total: len(groups), Failures: failures}
+	}
+	return nil
+}
+
+func (c *GoogleChat) eventMessage(a *action.Action) map[string]interface{} {
+	name := c.Accounts.Name(a.AccountID, a.IdentityAccountID)
+
+	widgets := []interface{}{
+		chatText("Account", name),
+		chatText("Source", a.EventSource),
+		chatText("User", a.UserName),
+	}
+	if a.Via != "" && a.Client != useragent.Unknown {
+		widgets = append(widgets, chatText("Via", a.Via))
+	}
+	if a.ErrorCode != "" {
+		widgets = append(widgets, chatHTML("Error", "<font color=\"#d13212\">"+html.EscapeString(a.ErrorCode)+"</font>"))
+	}
+	if len(a.Tags) > 0 {
+		widgets = append(widgets, chatText("Tags", strings.Join(a.Tags, ", ")))
+	}
+	if a.Detail != "" {
+		widgets = append(widgets, chatText("Detail", a.Detail))
+	}
+	if a.HasTag(detect.RootTag) {
+		widgets = append(widgets, chatText("Source IP", a.SourceIP), chatText("User Agent", a.UserAgent))
+	}
+	if w := chatSeverity(a.Severity); w != nil {
+		widgets = append(widgets, w)
+	}
+	widgets = append(widgets, map[string]interface{}{
+		"buttonList": map[string]interface{}{
+			"buttons": []interface{}{
+				map[string]interface{}{
+					"text": "View in CloudTrail",
+					"onClick": map[string]interface{}{
+						"openLink": map[string]string{"url": a.ConsoleURL()},
+					},
+				},
+			},
+		},
+	})
+
+	return chatCard(
+		a.EventID,
+		fmt.Sprintf("%s | %s | %s", name, a.EventName, a.UserName),
+		a.EventName,
+		a.EventTimeString(),
+		widgets,
+	)
+}
+
+func (c *GoogleChat) digestMessage(g *digest.Group) map[string]interface{} {
+	first := g.First()
+	name := c.Accounts.Name(first.AccountID, first.IdentityAccountID)
+	users := strings.Join(g.Users(), ", ")
+
+	var lines []string
+	for _, count := range g.Counts() {
+		lines = append(lines, fmt.Sprintf("<b>%s</b> x%d", html.EscapeString(count.EventName), count.Count))
+	}
+
+	widgets := []interface{}{
+		map[string]interface{}{
+			"textParagraph": map[string]string{"text": strings.Join(lines, "<br>")},
+		},
+		chatText("Account", name),
+		chatText("Users", users),
+		chatText("Time", timeRange(g)),
+	}
+	if n := g.Errors(); n > 0 {
+		widgets = append(widgets, chatText("Errors", fmt.Sprintf("%d", n)))
+	}
+	if w := chatSeverity(severity.Max(g.Actions)); w != nil {
+		widgets = append(widgets, w)
+	}
+
+	return chatCard(
+		g.Key,
+		fmt.Sprintf("%s | %d actions | %s", name, len(g.Actions), users),
+		fmt.Sprintf("%d console actions", len(g.Actions)),
+		users,
+		widgets,
+	)
+}
+
+// chatCard wraps widgets in a cardsV2 message. text is shown in
+// notifications and by clients that cannot render cards.
+func chatCard(id, text, title, subtitle string, widgets []interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"text": text,
+		"cardsV2": []interface{}{
+			map[string]interface{}{
+				"cardId": id,
+				"card": map[string]interface{}{
+					"header": map[string]string{
+						"title":    title,
+						"subtitle": subtitle,
+					},
+					"sections": []interface{}{
+						map[string]interface{}{"widgets": widgets},
+					},
+				},
+			},
+		},
+	}
+}
+
+// chatText is a labelled widget showing text as is. Card text is read as
+// HTML, so it is escaped.
+func chatText(label, text string) map[string]interface{} {
+	return chatHTML(label, html.EscapeString(text))
+}
+
+// chatHTML is a labelled widget showing formatted text, whose interpolated
+// values the caller has escaped.
+func chatHTML(label, markup string) map[string]interface{} {
+	return map[string]interface{}{
+		"decoratedText": map[string]string{
+			"topLabel": label,
+			"text":     fieldValue(markup),
+		},
+	}
+}
+
+// chatSeverity shows a severity in its color, or returns nil when unscored.
+func chatSeverity(sev string) map[string]interface{} {
+	style, ok := severityStyles[sev]
+	if !ok {
+		return nil
+	}
+	return chatHTML("Severity", fmt.Sprintf("<font color=\"%s\">%s</font>", hexColor(style.Color), sev))
+}
@@ -0,0 +1,96 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultHTTPAttempts = 5
+	defaultHTTPBackoff  = 500 * time.Millisecond
+)
+
+// httpPoster sends request bodies and retries when the receiver reports
+// backpressure (429 or 503) or any other server side failure.
+type httpPoster struct {
+	Client   *http.Client
+	Attempts int
+	Backoff  time.Duration
+
+	// Sign, when set, is called on every request before it is sent.
+	Sign func(req *http.Request, body []byte) error
+}
+
+func newHTTPPoster() httpPoster {
+	return httpPoster{
+		Client:   &http.Client{Timeout: 10 * time.Second},
+		Attempts: defaultHTTPAttempts,
+		Backoff:  defaultHTTPBackoff,
+	}
+}
+
+// httpStatusError is returned when the receiver answers with a non 2xx status.
+type httpStatusError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("http %d: %s", e.StatusCode, e.Body)
+}
+
+func (p *httpPoster) post(url string, headers map[string]string, body []byte) ([]byte, error) {
+	return p.do(http.MethodPost, url, headers, body)
+}
+
+func (p *httpPoster) do(method, url string, headers map[string]string, body []byte) ([]byte, error) {
+	var lastErr error
+
+	for attempt := 0; attempt < p.Attempts; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(p.Backoff, attempt))
+		}
+
+		req, err := http.NewRequest(method, url, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		for k, v := range headers {
+			req.Header.Set(k, v)
+		}
+		if p.Sign != nil {
+			if err := p.Sign(req, body); err != nil {
+				return nil, err
+			}
+		}
+
+		resp, err := p.Client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		respBody, _ := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+
+		if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+			return respBody, nil
+		}
+
+		lastErr = &httpStatusError{StatusCode: resp.StatusCode, Body: string(respBody)}
+		if resp.StatusCode != http.StatusTooManyRequests && resp.StatusCode < 500 {
+			return respBody, lastErr
+		}
+	}
+
+	return nil, fmt.Errorf("giving up after %d attempts: %v", p.Attempts, lastErr)
+}
+
+// backoff returns how long to wait before retry attempt n, starting at base
+// and doubling with every attempt. Sinks that retry part of a batch
+// themselves, such as throttled entries, use the same schedule.
+func backoff(base time.Duration, n int) time.Duration {
+	return base << uint(n-1)
+}
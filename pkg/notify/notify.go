@@ -0,0 +1,174 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+)
+
+// Notifier delivers kept console actions to a destination. Chat style
+// notifiers send one message per group, stream style notifiers flatten the
+// groups and deliver every action.
+type Notifier interface {
+	Name() string
+	Notify(groups []*digest.Group) error
+}
+
+// Resender is implemented by notifiers that can deliver a payload rendered by
+// an earlier Notify call as is. SNS, SQS and EventBridge also set message
+// attributes or entry fields from the action, and email has no single
+// payload, so those are rendered again instead.
+type Resender interface {
+	Resend(payload []byte) error
+}
+
+// Failure is a single message a notifier could not deliver.
+type Failure struct {
+	Payload []byte
+	Actions []*action.Action
+	Err     error
+}
+
+// DeliveryError is returned by notifiers when some messages could not be
+// delivered. It reports only the messages that failed, each with the actions
+// it carried, so they can be dead lettered without the delivered ones.
+type DeliveryError struct {
+	Kind     string
+	Total    int
+	Failures []Failure
+}
+
+func (e *DeliveryError) Error() string {
+	last := e.Failures[len(e.Failures)-1].Err
+	return fmt.Sprintf("%d of %d %s messages failed: %v", len(e.Failures), e.Total, e.Kind, last)
+}
+
+// Undelivered returns the actions err reports as not delivered: those of the
+// failed messages for a DeliveryError, otherwise, such as when the notifier
+// could not be built, every action in groups.
+func Undelivered(groups []*digest.Group, err error) []*action.Action {
+	var delivery *DeliveryError
+	if !errors.As(err, &delivery) {
+		return digest.Flatten(groups)
+	}
+
+	var actions []*action.Action
+	for _, f := range delivery.Failures {
+		actions = append(actions, f.Actions...)
+	}
+	return actions
+}
+
+// Destination configures a single notifier. URL is the webhook or queue URL,
+// Target the ARN or name of an AWS resource, and Region and Endpoint override
+// the AWS client settings. Format selects how stream sinks encode actions.
+type Destination struct {
+	Type     string            `json:"type"`
+	Format   string            `json:"format"`
+	URL      string            `json:"url"`
+	Channel  string            `json:"channel"`
+	Target   string            `json:"target"`
+	Region   string            `json:"region"`
+	Endpoint string            `json:"endpoint"`
+	Options  map[string]string `json:"options"`
+}
+
+// Summarizes reports whether the destination sends a single summary for
+// each Notify call, as email does, so splitting a file's actions across calls
+// would split the summary.
+func (d Destination) Summarizes() bool {
+	return d.Type == "ses" || d.Type == "smtp"
+}
+
+// Set builds and caches notifiers for the configured destinations.
+type Set struct {
+	Destinations map[string]Destination
+	Accounts     account.Directory
+
+	notifiers map[string]Notifier
+}
+
+// NewSet returns a Set for the given destinations.
+func NewSet(destinations map[string]Destination, accounts account.Directory) *Set {
+	return &Set{
+		Destinations: destinations,
+		Accounts:     accounts,
+		notifiers:    make(map[string]Notifier),
+	}
+}
+
+// Get returns the notifier for a destination, overriding its channel when
+// channel is not empty.
+func (s *Set) Get(name, channel string) (Notifier, error) {
+	key := name + "|" + channel
+	if n, ok := s.notifiers[key]; ok {
+		return n, nil
+	}
+
+	dest, ok := s.Destinations[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown destination %q", name)
+	}
+	if channel != "" {
+		dest.Channel = channel
+	}
+
+	n, err := New(name, dest, s.Accounts)
+	if err != nil {
+		return nil, fmt.Errorf("destination %q: %v", name, err)
+	}
+	s.notifiers[key] = n
+	return n, nil
+}
+
+// New builds a notifier for a destination.
+func New(name string, dest Destination, accounts account.Directory) (Notifier, error) {
+	if err := validFormat(dest.Format); err != nil {
+		return nil, err
+	}
+
+	switch dest.Type {
+	case "slack", "":
+		if dest.URL == "" {
+			return nil, fmt.Errorf("slack destination requires a url")
+		}
+		return &Slack{
+			name:     name,
+			Webhook:  dest.URL,
+			Channel:  dest.Channel,
+			Accounts: accounts,
+		}, nil
+	case "discord":
+		return newDiscord(name, dest, accounts)
+	case "googlechat":
+		return newGoogleChat(name, dest, accounts)
+	case "sns":
+		return newSNS(name, dest)
+	case "sqs":
+		return newSQS(name, dest)
+	case "eventbridge":
+		return newEventBridge(name, dest)
+	case "ses", "smtp":
+		return newEmail(name, dest, accounts)
+	case "splunk":
+		return newSplunk(name, dest)
+	case "opensearch", "elasticsearch":
+		return newOpenSearch(name, dest)
+	case "syslog":
+		return newSyslog(name, dest)
+	case "datadog":
+		return newDatadog(name, dest)
+	}
+	return nil, fmt.Errorf("unknown destination type %q", dest.Type)
+}
+
+// getOption returns a destination option or fallback when it is unset.
+func getOption(dest Destination, key, fallback string) string {
+	if v, ok := dest.Options[key]; ok && v != "" {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,303 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/ocsf"
+	"github.com/aws/aws-sdk-go/aws"
+	v4 "github.com/aws/aws-sdk-go/aws/signer/v4"
+)
+
+const (
+	defaultIndexPrefix = "console-actions"
+
+	// bulkAttempts bounds how often throttled bulk items are resubmitted.
+	bulkAttempts = 3
+)
+
+// OpenSearch indexes actions into daily indices through the _bulk API. It
+// works against OpenSearch and Elasticsearch, and signs requests with SigV4
+// when pointed at Amazon OpenSearch Service.
+type OpenSearch struct {
+	URL          string
+	IndexPrefix  string
+	TemplateName string
+	Username     string
+	Password     string
+	Format       string
+
+	poster            httpPoster
+	templateInstalled bool
+	name              string
+}
+
+// document is the indexed form of an action.
+type document struct {
+	Timestamp string `json:"@timestamp"`
+	*action.Action
+}
+
+func newOpenSearch(name string, dest Destination) (*OpenSearch, error) {
+	if dest.URL == "" {
+		return nil, fmt.Errorf("opensearch destination requires a url")
+	}
+
+	o := &OpenSearch{
+		URL:         strings.TrimSuffix(dest.URL, "/"),
+		IndexPrefix: getOption(dest, "index_prefix", defaultIndexPrefix),
+		Username:    getOption(dest, "username", ""),
+		Password:    getOption(dest, "password", ""),
+		Format:      dest.Format,
+		poster:      newHTTPPoster(),
+		name:        name,
+	}
+	o.TemplateName = getOption(dest, "template_name", o.IndexPrefix)
+
+	// options.sigv4 names the service to sign for, "es" for managed domains
+	// or "aoss" for serverless collections.
+	if service := getOption(dest, "sigv4", ""); service != "" {
+		region := dest.Region
+		sess := awsSession()
+		if region == "" {
+			region = aws.StringValue(sess.Config.Region)
+		}
+		signer := v4.NewSigner(sess.Config.Credentials)
+		o.poster.Sign = func(req *http.Request, body []byte) error {
+			_, err := signer.Sign(req, bytes.NewReader(body), service, region, time.Now())
+			return err
+		}
+	}
+
+	return o, nil
+}
+
+func (o *OpenSearch) Name() string {
+	return o.name
+}
+
+func (o *OpenSearch) Notify(groups []*digest.Group) error {
+	actions := digest.Flatten(groups)
+	if len(actions) == 0 {
+		return nil
+	}
+
+	// The bundled template only describes the native action fields
+	if !o.templateInstalled && o.Format != FormatOCSF {
+		if err := o.installTemplate(); err != nil {
+			return fmt.Errorf("installing index template: %v", err)
+		}
+		o.templateInstalled = true
+	}
+
+	var failures []Failure
+	var pending []*action.Action
+	var docs [][]byte
+	for _, a := range actions {
+		doc, err := o.render(a)
+		if err != nil {
+			failures = append(failures, Failure{Actions: []*action.Action{a}, Err: err})
+			continue
+		}
+		pending = append(pending, a)
+		docs = append(docs, doc)
+	}
+
+	for attempt := 0; attempt < bulkAttempts && len(pending) > 0; attempt++ {
+		if attempt > 0 {
+			time.Sleep(backoff(o.poster.Backoff, attempt))
+		}
+		results, err := o.bulk(bytes.Join(docs, nil))
+		if err != nil {
+			for i, a := range pending {
+				failures = append(failures, Failure{Payload: docs[i], Actions: []*action.Action{a}, Err: err})
+			}
+			pending = nil
+			break
+		}
+
+		var retry []*action.Action
+		var retryDocs [][]byte
+		for i, a := range pending {
+			if i >= len(results) || results[i] == nil {
+				continue
+			}
+			if results[i].throttled {
+				retry = append(retry, a)
+				retryDocs = append(retryDocs, docs[i])
+				continue
+			}
+			failures = append(failures, Failure{Payload: docs[i], Actions: []*action.Action{a}, Err: results[i]})
+		}
+		pending, docs = retry, retryDocs
+	}
+
+	for i, a := range pending {
+		failures = append(failures, Failure{Payload: docs[i], Actions: []*action.Action{a}, Err: fmt.Errorf("throttled")})
+	}
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "opensearch", Total: len(actions), Failures: failures}
+	}
+	return nil
+}
+
+// Resend indexes previously rendered bulk lines.
+func (o *OpenSearch) Resend(payload []byte) error {
+	results, err := o.bulk(payload)
+	if err != nil {
+		return err
+	}
+	for _, r := range results {
+		if r != nil {
+			return r
+		}
+	}
+	return nil
+}
+
+// render returns the bulk action and document lines indexing a.
+func (o *OpenSearch) render(a *action.Action) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	meta := map[string]map[string]string{
+		"index": {"_index": o.Index(a), "_id": a.EventID},
+	}
+	if err := enc.Encode(meta); err != nil {
+		return nil, err
+	}
+	var doc interface{} = document{Timestamp: a.EventTimeString(), Action: a}
+	if o.Format == FormatOCSF {
+		doc = ocsf.FromAction(a)
+	}
+	if err := enc.Encode(doc); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// bulkError is a document the _bulk API rejected.
+type bulkError struct {
+	Type      string
+	Reason    string
+	throttled bool
+}
+
+func (e *bulkError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Type, e.Reason)
+}
+
+// bulk sends rendered documents and returns the result of each, nil for
+// those that were indexed.
+func (o *OpenSearch) bulk(body []byte) ([]*bulkError, error) {
+	resp, err := o.poster.post(o.URL+"/_bulk", o.headers("application/x-ndjson"), body)
+	if err != nil {
+		return nil, fmt.Errorf("bulk request failed: %v", err)
+	}
+
+	var result struct {
+		Errors bool `json:"errors"`
+		Items  []map[string]struct {
+			Status int `json:"status"`
+			Error  *struct {
+				Type   string `json:"type"`
+				Reason string `json:"reason"`
+			} `json:"error"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil {
+		return nil, fmt.Errorf("parsing bulk response: %v", err)
+	}
+
+	results := make([]*bulkError, len(result.Items))
+	if !result.Errors {
+		return results, nil
+	}
+	for i, item := range result.Items {
+		for _, r := range item {
+			if r.Error == nil {
+				continue
+			}
+			results[i] = &bulkError{
+				Type:      r.Error.Type,
+				Reason:    r.Error.Reason,
+				throttled: r.Status == http.StatusTooManyRequests,
+			}
+		}
+	}
+	return results, nil
+}
+
+// Index returns the daily index an action is written to.
+func (o *OpenSearch) Index(a *action.Action) string {
+	t := a.EventTime
+	if t.IsZero() {
+		t = time.Now()
+	}
+	return fmt.Sprintf("%s-%s", o.IndexPrefix, t.UTC().Format("2006.01.02"))
+}
+
+// installTemplate creates or updates the index template covering the daily
+// indices so the typed action fields get consistent mappings.
+func (o *OpenSearch) installTemplate() error {
+	keyword := map[string]string{"type": "keyword"}
+	template := map[string]interface{}{
+		"index_patterns": []string{o.IndexPrefix + "-*"},
+		"template": map[string]interface{}{
+			"mappings": map[string]interface{}{
+				"properties": map[string]interface{}{
+					"@timestamp":          map[string]string{"type": "date"},
+					"event_time":          map[string]string{"type": "date"},
+					"event_id":            keyword,
+					"event_name":          keyword,
+					"event_source":        keyword,
+					"account_id":          keyword,
+					"identity_account_id": keyword,
+					"region":              keyword,
+					"user_name":           keyword,
+					"principal":           keyword,
+					"error_code":          keyword,
+					"source_ip":           keyword,
+					"s3_uri":              keyword,
+					"severity":            keyword,
+					"client":              keyword,
+					"via":                 keyword,
+					"tags":                keyword,
+					"detail": map[string]interface{}{
+						"type": "text",
+						"fields": map[string]interface{}{
+							"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 512},
+						},
+					},
+					"user_agent": map[string]interface{}{
+						"type": "text",
+						"fields": map[string]interface{}{
+							"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 512},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	body, err := json.Marshal(template)
+	if err != nil {
+		return err
+	}
+	_, err = o.poster.do(http.MethodPut, o.URL+"/_index_template/"+o.TemplateName, o.headers("application/json"), body)
+	return err
+}
+
+func (o *OpenSearch) headers(contentType string) map[string]string {
+	headers := map[string]string{"Content-Type": contentType}
+	if o.Username != "" {
+		headers["Authorization"] = "Basic " + base64.StdEncoding.EncodeToString([]byte(o.Username+":"+o.Password))
+	}
+	return headers
+}
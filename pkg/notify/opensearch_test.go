@@ -0,0 +1,107 @@
+package notify
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOpenSearchNotify(t *testing.T) {
+	withFakeAWS(t)
+
+	var templates, bulks int
+	var indices []string
+	var template struct {
+		Template struct {
+			Mappings struct {
+				Properties map[string]map[string]interface{}
+			}
+		}
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256") {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		switch {
+		case r.Method == http.MethodPut && r.URL.Path == "/_index_template/console-actions":
+			templates++
+			json.NewDecoder(r.Body).Decode(&template)
+			fmt.Fprint(w, `{"acknowledged":true}`)
+		case r.URL.Path == "/_bulk":
+			bulks++
+			var items []string
+			scanner := bufio.NewScanner(r.Body)
+			for i := 0; scanner.Scan(); i++ {
+				var meta map[string]map[string]string
+				json.Unmarshal(scanner.Bytes(), &meta)
+				scanner.Scan()
+				indices = append(indices, meta["index"]["_index"])
+
+				switch {
+				case bulks == 1 && meta["index"]["_id"] == "event-1":
+					items = append(items, `{"index":{"status":429,"error":{"type":"es_rejected_execution_exception","reason":"queue full"}}}`)
+				case meta["index"]["_id"] == "event-2":
+					items = append(items, `{"index":{"status":400,"error":{"type":"mapper_parsing_exception","reason":"bad"}}}`)
+				default:
+					items = append(items, `{"index":{"status":201}}`)
+				}
+			}
+			fmt.Fprintf(w, `{"errors":true,"items":[%s]}`, strings.Join(items, ","))
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := New("search", Destination{
+		Type:    "opensearch",
+		URL:     srv.URL,
+		Region:  "us-east-1",
+		Options: map[string]string{"sigv4": "es"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := testGroups(3)
+	for _, a := range groups[0].Actions {
+		a.EventTime = time.Date(2021, 5, 14, 19, 3, 40, 0, time.UTC)
+	}
+
+	n.(*OpenSearch).poster.Backoff = 20 * time.Millisecond
+	start := time.Now()
+	err = n.Notify(groups)
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("expected throttled items to be retried after a backoff, took %v", elapsed)
+	}
+	var delivery *DeliveryError
+	if !errors.As(err, &delivery) || len(delivery.Failures) != 1 {
+		t.Fatalf("expected event-2 to fail permanently, got %v", err)
+	}
+	if f := delivery.Failures[0]; f.Actions[0].EventID != "event-2" || f.Err.Error() != "mapper_parsing_exception: bad" || !bytes.Contains(f.Payload, []byte(`"_id":"event-2"`)) {
+		t.Fatalf("unexpected failure %+v", f)
+	}
+	if templates != 1 || bulks != 2 {
+		t.Fatalf("expected 1 template install and 2 bulk calls, got %d and %d", templates, bulks)
+	}
+	if indices[0] != "console-actions-2021.05.14" {
+		t.Fatalf("unexpected index %s", indices[0])
+	}
+
+	properties := template.Template.Mappings.Properties
+	for _, field := range []string{"client", "via", "tags", "severity"} {
+		if properties[field]["type"] != "keyword" {
+			t.Errorf("expected %s to be mapped as a keyword, got %v", field, properties[field])
+		}
+	}
+	if properties["detail"]["type"] != "text" {
+		t.Errorf("expected detail to be mapped as text, got %v", properties["detail"])
+	}
+}
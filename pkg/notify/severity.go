@@ -0,0 +1,34 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+)
+
+// severityStyle is how chat notifiers present a severity.
+type severityStyle struct {
+	Emoji string
+	Color int
+}
+
+var severityStyles = map[string]severityStyle{
+	severity.Critical: {":rotating_light:", 0xb60205},
+	severity.High:     {":red_circle:", 0xd93f0b},
+	severity.Medium:   {":large_orange_circle:", 0xfbca04},
+	severity.Low:      {":large_blue_circle:", 0x1d76db},
+	severity.Info:     {":white_circle:", 0xc5c5c5},
+}
+
+// emojiPrefix returns the severity's emoji followed by a space, or nothing
+// for unscored actions.
+func emojiPrefix(sev string) string {
+	if style, ok := severityStyles[sev]; ok {
+		return style.Emoji + " "
+	}
+	return ""
+}
+
+func hexColor(color int) string {
+	return fmt.Sprintf("#%06x", color)
+}
@@ -0,0 +1,205 @@
+package notify
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/detect"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/useragent"
+	log "github.com/sirupsen/logrus"
+)
+
+// Slack posts actions to an incoming webhook.
+type Slack struct {
+	Webhook  string
+	Channel  string
+	Accounts account.Directory
+
+	name string
+}
+
+func (s *Slack) Name() string {
+	return s.name
+}
+
+func (s *Slack) Notify(groups []*digest.Group) error {
+	var failures []Failure
+	for _, group := range groups {
+		var slackBody []byte
+		if group.IsDigest() {
+			slackBody = s.digestBody(group)
+		} else {
+			slackBody = s.eventBody(group.First())
+		}
+
+		err := SendSlackNotification(s.Webhook, slackBody)
+		if err != nil {
+			log.Debugln(string(slackBody))
+			failures = append(failures, Failure{Payload: slackBody, Actions: group.Actions, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "slack", Total: len(groups), Failures: failures}
+	}
+	return nil
+}
+
+// Resend posts a previously rendered Slack message body.
+func (s *Slack) Resend(payload []byte) error {
+	return SendSlackNotification(s.Webhook, payload)
+}
+
+func (s *Slack) eventBody(a *action.Action) []byte {
+	var errorCode string
+	if a.ErrorCode != "" {
+		errorCode = fmt.Sprintf(" - `%s`", a.ErrorCode)
+	}
+	var detail string
+	if a.Detail != "" {
+		detail = "\n" + a.Detail
+	}
+
+	name := s.Accounts.Name(a.AccountID, a.IdentityAccountID)
+	elements := []map[string]string{
+		{"type": "mrkdwn", "text": name},
+		{"type": "mrkdwn", "text": a.UserName},
+		{"type": "mrkdwn", "text": fmt.Sprintf("<%s|%s>", a.ConsoleURL(), a.EventTimeString())},
+	}
+	if a.Via != "" && a.Client != useragent.Unknown {
+		elements = append(elements, map[string]string{"type": "mrkdwn", "text": "via " + a.Via})
+	}
+	if len(a.Tags) > 0 {
+		elements = append(elements, map[string]string{"type": "mrkdwn", "text": ":eyes: " + strings.Join(a.Tags, ", ")})
+	}
+	// Root use needs the origin to tell a legitimate owner from a stolen password
+	if a.HasTag(detect.RootTag) {
+		elements = append(elements,
+			map[string]string{"type": "mrkdwn", "text": "IP `" + a.SourceIP + "`"},
+			map[string]string{"type": "mrkdwn", "text": "UA `" + a.UserAgent + "`"},
+		)
+	}
+
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("%s*%s* - %s%s%s", emojiPrefix(a.Severity), a.EventName, a.EventSource, errorCode, detail),
+			},
+		},
+		map[string]interface{}{
+			"type":     "context",
+			"elements": elements,
+		},
+	}
+
+	return slackMessage(s.Channel, fmt.Sprintf("%s | %s | %s", name, a.EventName, a.UserName), blocks, a.Severity)
+}
+
+// digestBody summarizes a digest group as a single Slack message listing
+// each event name with its count, the time range covered and the error count.
+func (s *Slack) digestBody(g *digest.Group) []byte {
+	first := g.First()
+	name := s.Accounts.Name(first.AccountID, first.IdentityAccountID)
+	users := strings.Join(g.Users(), ", ")
+
+	var lines []string
+	for _, c := range g.Counts() {
+		lines = append(lines, fmt.Sprintf("`%s` x%d", c.EventName, c.Count))
+	}
+
+	elements := []map[string]string{
+		{"type": "mrkdwn", "text": name},
+		{"type": "mrkdwn", "text": users},
+		{"type": "mrkdwn", "text": timeRange(g)},
+	}
+	if n := g.Errors(); n > 0 {
+		elements = append(elements, map[string]string{"type": "mrkdwn", "text": fmt.Sprintf("%d errors", n)})
+	}
+
+	sev := severity.Max(g.Actions)
+	blocks := []interface{}{
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{
+				"type": "mrkdwn",
+				"text": fmt.Sprintf("%s*%d console actions* - %s", emojiPrefix(sev), len(g.Actions), users),
+			},
+		},
+		map[string]interface{}{
+			"type": "section",
+			"text": map[string]string{
+				"type": "mrkdwn",
+				"text": strings.Join(lines, "\n"),
+			},
+		},
+		map[string]interface{}{
+			"type":     "context",
+			"elements": elements,
+		},
+	}
+
+	return slackMessage(s.Channel, fmt.Sprintf("%s | %d actions | %s", name, len(g.Actions), users), blocks, sev)
+}
+
+// slackMessage builds a message body. Scored messages carry their blocks in
+// an attachment, the only way to give a Slack message a colored bar.
+func slackMessage(channel, text string, blocks []interface{}, sev string) []byte {
+	body := map[string]interface{}{
+		"channel": channel,
+		"text":    text,
+	}
+	if style, ok := severityStyles[sev]; ok {
+		body["attachments"] = []interface{}{
+			map[string]interface{}{
+				"color":  hexColor(style.Color),
+				"blocks": blocks,
+			},
+		}
+	} else {
+		body["blocks"] = blocks
+	}
+
+	b, _ := json.Marshal(body)
+	return b
+}
+
+// timeRange formats the span of event times covered by a digest group.
+func timeRange(g *digest.Group) string {
+	start, end := g.TimeRange()
+	return fmt.Sprintf("%s - %s", start.UTC().Format(time.RFC3339), end.UTC().Format(time.RFC3339))
+}
+
+func SendSlackNotification(webhookUrl string, slackBody []byte) error {
+
+	req, err := http.NewRequest(http.MethodPost, webhookUrl, bytes.NewBuffer(slackBody))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Add("Content-Type", "application/json")
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	buf := new(bytes.Buffer)
+	buf.ReadFrom(resp.Body)
+	if buf.String() != "ok" {
+		return errors.New(fmt.Sprintf("Non-ok response returned from Slack: %s", buf.String()))
+	}
+	return nil
+}
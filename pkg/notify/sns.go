@@ -0,0 +1,72 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sns"
+	"github.com/aws/aws-sdk-go/service/sns/snsiface"
+)
+
+// SNS publishes every action to a topic as JSON with message attributes
+// suitable for subscription filter policies.
+type SNS struct {
+	TopicArn string
+	Client   snsiface.SNSAPI
+	Format   string
+
+	name string
+}
+
+func newSNS(name string, dest Destination) (*SNS, error) {
+	if dest.Target == "" {
+		return nil, fmt.Errorf("sns destination requires a target topic arn")
+	}
+	return &SNS{
+		TopicArn: dest.Target,
+		Format:   dest.Format,
+		Client:   sns.New(awsSession(), awsConfig(dest)),
+		name:     name,
+	}, nil
+}
+
+func (s *SNS) Name() string {
+	return s.name
+}
+
+func (s *SNS) Notify(groups []*digest.Group) error {
+	actions := digest.Flatten(groups)
+
+	var failures []Failure
+	for _, a := range actions {
+		message, err := marshalAction(a, s.Format)
+		if err != nil {
+			failures = append(failures, Failure{Actions: []*action.Action{a}, Err: err})
+			continue
+		}
+
+		attrs := make(map[string]*sns.MessageAttributeValue)
+		for k, v := range attributes(a) {
+			attrs[k] = &sns.MessageAttributeValue{
+				DataType:    aws.String("String"),
+				StringValue: aws.String(v),
+			}
+		}
+
+		_, err = s.Client.Publish(&sns.PublishInput{
+			TopicArn:          aws.String(s.TopicArn),
+			Message:           aws.String(message),
+			MessageAttributes: attrs,
+		})
+		if err != nil {
+			failures = append(failures, Failure{Payload: []byte(message), Actions: []*action.Action{a}, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "sns", Total: len(actions), Failures: failures}
+	}
+	return nil
+}
@@ -0,0 +1,210 @@
+package notify
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+)
+
+const (
+	defaultSplunkBatchSize  = 100
+	defaultSplunkSource     = "cloudtrail-console-actions"
+	defaultSplunkSourcetype = "aws:cloudtrail:console"
+
+	// splunkAckPolls bounds how long we wait for indexer acknowledgement.
+	splunkAckPolls = 10
+)
+
+// Splunk sends actions to an HTTP Event Collector in batches. When
+// acknowledgement is enabled each batch is only considered delivered once the
+// indexers confirm it.
+type Splunk struct {
+	URL        string
+	Token      string
+	Index      string
+	Source     string
+	Sourcetype string
+	BatchSize  int
+	Ack        bool
+	Channel    string
+	AckDelay   time.Duration
+	Format     string
+
+	poster httpPoster
+	name   string
+}
+
+// splunkEvent is a single HEC event envelope.
+type splunkEvent struct {
+	Time       float64     `json:"time,omitempty"`
+	Host       string      `json:"host,omitempty"`
+	Source     string      `json:"source,omitempty"`
+	Sourcetype string      `json:"sourcetype,omitempty"`
+	Index      string      `json:"index,omitempty"`
+	Event      interface{} `json:"event"`
+}
+
+func newSplunk(name string, dest Destination) (*Splunk, error) {
+	if dest.URL == "" {
+		return nil, fmt.Errorf("splunk destination requires a url")
+	}
+	token := getOption(dest, "token", "")
+	if token == "" {
+		return nil, fmt.Errorf("splunk destination requires options.token")
+	}
+
+	s := &Splunk{
+		URL:        strings.TrimSuffix(dest.URL, "/"),
+		Token:      token,
+		Index:      getOption(dest, "index", ""),
+		Source:     getOption(dest, "source", defaultSplunkSource),
+		Sourcetype: getOption(dest, "sourcetype", defaultSplunkSourcetype),
+		BatchSize:  defaultSplunkBatchSize,
+		Ack:        getOption(dest, "ack", "") == "true",
+		Channel:    getOption(dest, "channel", ""),
+		AckDelay:   time.Second,
+		Format:     dest.Format,
+		poster:     newHTTPPoster(),
+		name:       name,
+	}
+	if v, err := strconv.Atoi(getOption(dest, "batch_size", "")); err == nil && v > 0 {
+		s.BatchSize = v
+	}
+	if s.Ack && s.Channel == "" {
+		s.Channel = newChannelID()
+	}
+	return s, nil
+}
+
+func (s *Splunk) Name() string {
+	return s.name
+}
+
+func (s *Splunk) Notify(groups []*digest.Group) error {
+	actions := digest.Flatten(groups)
+
+	var failures []Failure
+	var batches int
+	for start := 0; start < len(actions); start += s.BatchSize {
+		end := start + s.BatchSize
+		if end > len(actions) {
+			end = len(actions)
+		}
+		batches++
+
+		body, sent, unencodable := s.render(actions[start:end])
+		failures = append(failures, unencodable...)
+		if len(sent) == 0 {
+			continue
+		}
+		if err := s.Resend(body); err != nil {
+			failures = append(failures, Failure{Payload: body, Actions: sent, Err: err})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "splunk", Total: batches, Failures: failures}
+	}
+	return nil
+}
+
+// render encodes a batch as HEC events. It returns the body, the actions it
+// holds and a failure for each action that could not be encoded.
+func (s *Splunk) render(batch []*action.Action) ([]byte, []*action.Action, []Failure) {
+	var body bytes.Buffer
+	var sent []*action.Action
+	var failures []Failure
+	for _, a := range batch {
+		e := splunkEvent{
+			Host:       a.AccountID,
+			Source:     s.Source,
+			Sourcetype: s.Sourcetype,
+			Index:      s.Index,
+			Event:      encode(a, s.Format),
+		}
+		if !a.EventTime.IsZero() {
+			e.Time = float64(a.EventTime.UnixNano()) / float64(time.Second)
+		}
+		line, err := json.Marshal(e)
+		if err != nil {
+			failures = append(failures, Failure{Actions: []*action.Action{a}, Err: err})
+			continue
+		}
+		body.Write(line)
+		body.WriteByte('\n')
+		sent = append(sent, a)
+	}
+	return body.Bytes(), sent, failures
+}
+
+// Resend posts a batch of rendered HEC events, waiting for acknowledgement
+// when it is enabled.
+func (s *Splunk) Resend(payload []byte) error {
+	resp, err := s.poster.post(s.URL+"/services/collector/event", s.headers(), payload)
+	if err != nil {
+		return err
+	}
+	if !s.Ack {
+		return nil
+	}
+
+	var result struct {
+		AckID *int64 `json:"ackId"`
+	}
+	if err := json.Unmarshal(resp, &result); err != nil || result.AckID == nil {
+		return fmt.Errorf("acknowledgement requested but no ackId returned: %s", resp)
+	}
+	return s.waitForAck(*result.AckID)
+}
+
+// waitForAck polls the ack endpoint until the indexers confirm the batch.
+func (s *Splunk) waitForAck(id int64) error {
+	body, _ := json.Marshal(map[string][]int64{"acks": {id}})
+	key := strconv.FormatInt(id, 10)
+
+	for poll := 0; poll < splunkAckPolls; poll++ {
+		resp, err := s.poster.post(s.URL+"/services/collector/ack", s.headers(), body)
+		if err != nil {
+			return err
+		}
+
+		var result struct {
+			Acks map[string]bool `json:"acks"`
+		}
+		if err := json.Unmarshal(resp, &result); err != nil {
+			return fmt.Errorf("parsing ack response: %v", err)
+		}
+		if result.Acks[key] {
+			return nil
+		}
+		time.Sleep(s.AckDelay)
+	}
+	return fmt.Errorf("ack %d not confirmed after %d polls", id, splunkAckPolls)
+}
+
+func (s *Splunk) headers() map[string]string {
+	headers := map[string]string{
+		"Authorization": "Splunk " + s.Token,
+		"Content-Type":  "application/json",
+	}
+	if s.Channel != "" {
+		headers["X-Splunk-Request-Channel"] = s.Channel
+	}
+	return headers
+}
+
+// newChannelID returns a random GUID for the HEC request channel.
+func newChannelID() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	b[6] = (b[6] & 0x0f) | 0x40
+	b[8] = (b[8] & 0x3f) | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
@@ -0,0 +1,89 @@
+package notify
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplunkNotify(t *testing.T) {
+	var mu sync.Mutex
+	var events []map[string]interface{}
+	var eventCalls, ackCalls int
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if r.Header.Get("Authorization") != "Splunk secret" || r.Header.Get("X-Splunk-Request-Channel") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/services/collector/event":
+			eventCalls++
+			if eventCalls == 1 {
+				// Indexer backpressure
+				w.WriteHeader(http.StatusServiceUnavailable)
+				fmt.Fprint(w, `{"text":"Server is busy","code":9}`)
+				return
+			}
+			scanner := bufio.NewScanner(r.Body)
+			for scanner.Scan() {
+				var e map[string]interface{}
+				json.Unmarshal(scanner.Bytes(), &e)
+				events = append(events, e)
+			}
+			fmt.Fprintf(w, `{"text":"Success","code":0,"ackId":%d}`, eventCalls)
+		case "/services/collector/ack":
+			ackCalls++
+			var in struct{ Acks []int }
+			json.NewDecoder(r.Body).Decode(&in)
+			fmt.Fprintf(w, `{"acks":{"%d":%t}}`, in.Acks[0], ackCalls > 1)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	n, err := New("hec", Destination{
+		Type:    "splunk",
+		URL:     srv.URL,
+		Options: map[string]string{"token": "secret", "ack": "true", "index": "aws"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := n.(*Splunk)
+	s.poster.Backoff = time.Millisecond
+	s.AckDelay = time.Millisecond
+
+	groups := testGroups(3)
+	for _, a := range groups[0].Actions {
+		a.EventTime = time.Date(2021, 5, 14, 19, 3, 40, 0, time.UTC)
+	}
+
+	if err := n.Notify(groups); err != nil {
+		t.Fatal(err)
+	}
+
+	if eventCalls != 2 || ackCalls != 2 {
+		t.Fatalf("expected a retry and two ack polls, got %d event and %d ack calls", eventCalls, ackCalls)
+	}
+	if len(events) != 3 {
+		t.Fatalf("expected 3 events, got %d", len(events))
+	}
+
+	e := events[0]
+	if e["host"] != "123456789012" || e["sourcetype"] != "aws:cloudtrail:console" || e["index"] != "aws" || e["time"] != float64(1621019020) {
+		t.Fatalf("unexpected envelope %v", e)
+	}
+	if event, ok := e["event"].(map[string]interface{}); !ok || !strings.HasPrefix(event["event_id"].(string), "event-") {
+		t.Fatalf("unexpected event %v", e["event"])
+	}
+}
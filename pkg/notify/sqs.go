@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/aws/aws-sdk-go/service/sqs/sqsiface"
+)
+
+// sqsBatchSize is the maximum number of entries SendMessageBatch accepts.
+const sqsBatchSize = 10
+
+// SQS sends every action to a queue as JSON with message attributes.
+type SQS struct {
+	QueueURL string
+	Client   sqsiface.SQSAPI
+	Format   string
+
+	name string
+}
+
+func newSQS(name string, dest Destination) (*SQS, error) {
+	if dest.URL == "" {
+		return nil, fmt.Errorf("sqs destination requires a queue url")
+	}
+	return &SQS{
+		QueueURL: dest.URL,
+		Format:   dest.Format,
+		Client:   sqs.New(awsSession(), awsConfig(dest)),
+		name:     name,
+	}, nil
+}
+
+func (s *SQS) Name() string {
+	return s.name
+}
+
+func (s *SQS) Notify(groups []*digest.Group) error {
+	actions := digest.Flatten(groups)
+
+	var failures []Failure
+	for start := 0; start < len(actions); start += sqsBatchSize {
+		end := start + sqsBatchSize
+		if end > len(actions) {
+			end = len(actions)
+		}
+
+		// Entry IDs are indexes into actions
+		var entries []*sqs.SendMessageBatchRequestEntry
+		bodies := make(map[int]string)
+		for i := start; i < end; i++ {
+			a := actions[i]
+			body, err := marshalAction(a, s.Format)
+			if err != nil {
+				failures = append(failures, Failure{Actions: []*action.Action{a}, Err: err})
+				continue
+			}
+			bodies[i] = body
+
+			attrs := make(map[string]*sqs.MessageAttributeValue)
+			for k, v := range attributes(a) {
+				attrs[k] = &sqs.MessageAttributeValue{
+					DataType:    aws.String("String"),
+					StringValue: aws.String(v),
+				}
+			}
+
+			entries = append(entries, &sqs.SendMessageBatchRequestEntry{
+				Id:                aws.String(strconv.Itoa(i)),
+				MessageBody:       aws.String(body),
+				MessageAttributes: attrs,
+			})
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		out, err := s.Client.SendMessageBatch(&sqs.SendMessageBatchInput{
+			QueueUrl: aws.String(s.QueueURL),
+			Entries:  entries,
+		})
+		if err != nil {
+			for _, e := range entries {
+				i, _ := strconv.Atoi(aws.StringValue(e.Id))
+				failures = append(failures, Failure{Payload: []byte(bodies[i]), Actions: []*action.Action{actions[i]}, Err: err})
+			}
+			continue
+		}
+
+		for _, f := range out.Failed {
+			i, err := strconv.Atoi(aws.StringValue(f.Id))
+			if err != nil || i < start || i >= end {
+				continue
+			}
+			failures = append(failures, Failure{
+				Payload: []byte(bodies[i]),
+				Actions: []*action.Action{actions[i]},
+				Err:     fmt.Errorf("%s: %s", aws.StringValue(f.Code), aws.StringValue(f.Message)),
+			})
+		}
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "sqs", Total: len(actions), Failures: failures}
+	}
+	return nil
+}
@@ -0,0 +1,261 @@
+package notify
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/digest"
+)
+
+const (
+	defaultSyslogAppName = "cloudtrail-console-actions"
+	defaultSyslogSDID    = "console@32473"
+	syslogMsgID          = "ConsoleAction"
+)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+var syslogSeverities = map[string]int{
+	"emerg": 0, "alert": 1, "crit": 2, "err": 3,
+	"warning": 4, "notice": 5, "info": 6, "debug": 7,
+}
+
+// Syslog sends each action as an RFC 5424 message over UDP, TCP or TLS. TCP
+// and TLS use octet counting framing from RFC 6587.
+type Syslog struct {
+	Network   string
+	Addr      string
+	Facility  int
+	AppName   string
+	SDID      string
+	Format    string
+	TLSConfig *tls.Config
+
+	// Severities maps action severities to syslog severities. Actions without
+	// a mapped severity use DefaultSeverity.
+	Severities      map[string]int
+	DefaultSeverity int
+
+	name string
+}
+
+func newSyslog(name string, dest Destination) (*Syslog, error) {
+	u, err := url.Parse(dest.URL)
+	if err != nil || u.Host == "" {
+		return nil, fmt.Errorf("syslog destination requires a url like tls://host:6514")
+	}
+
+	s := &Syslog{
+		Network: u.Scheme,
+		Addr:    u.Host,
+		AppName: getOption(dest, "app_name", defaultSyslogAppName),
+		SDID:    getOption(dest, "sd_id", defaultSyslogSDID),
+		Format:  dest.Format,
+		Severities: map[string]int{
+			"critical": syslogSeverities["crit"],
+			"high":     syslogSeverities["err"],
+			"medium":   syslogSeverities["warning"],
+			"low":      syslogSeverities["notice"],
+			"info":     syslogSeverities["info"],
+		},
+		name: name,
+	}
+
+	switch s.Network {
+	case "udp", "tcp":
+	case "tls":
+		s.TLSConfig = &tls.Config{ServerName: u.Hostname()}
+		if caFile := getOption(dest, "ca_file", ""); caFile != "" {
+			pem, err := ioutil.ReadFile(caFile)
+			if err != nil {
+				return nil, fmt.Errorf("ca_file: %v", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("ca_file: no certificates found")
+			}
+			s.TLSConfig.RootCAs = pool
+		}
+	default:
+		return nil, fmt.Errorf("unsupported syslog transport %q", s.Network)
+	}
+
+	var ok bool
+	if s.Facility, ok = syslogFacilities[getOption(dest, "facility", "local0")]; !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", dest.Options["facility"])
+	}
+	if s.DefaultSeverity, ok = syslogSeverities[getOption(dest, "severity", "notice")]; !ok {
+		return nil, fmt.Errorf("unknown syslog severity %q", dest.Options["severity"])
+	}
+
+	// options.severity_map overrides the mapping, e.g. "high=crit,low=info"
+	if m := getOption(dest, "severity_map", ""); m != "" {
+		for _, pair := range strings.Split(m, ",") {
+			kv := strings.SplitN(strings.TrimSpace(pair), "=", 2)
+			if len(kv) != 2 {
+				return nil, fmt.Errorf("invalid severity_map entry %q", pair)
+			}
+			sev, ok := syslogSeverities[kv[1]]
+			if !ok {
+				return nil, fmt.Errorf("unknown syslog severity %q", kv[1])
+			}
+			s.Severities[kv[0]] = sev
+		}
+	}
+
+	return s, nil
+}
+
+func (s *Syslog) Name() string {
+	return s.name
+}
+
+func (s *Syslog) Notify(groups []*digest.Group) error {
+	actions := digest.Flatten(groups)
+	if len(actions) == 0 {
+		return nil
+	}
+
+	var failures []Failure
+	var conn net.Conn
+	var dialErr error
+	for _, a := range actions {
+		msg, err := s.Message(a)
+		if err != nil {
+			failures = append(failures, Failure{Actions: []*action.Action{a}, Err: err})
+			continue
+		}
+		fail := func(err error) {
+			failures = append(failures, Failure{Payload: []byte(msg), Actions: []*action.Action{a}, Err: err})
+		}
+
+		// A receiver that cannot be reached is not tried again for every
+		// message
+		if conn == nil && dialErr == nil {
+			if conn, dialErr = s.dial(); dialErr != nil {
+				conn = nil
+			}
+		}
+		if dialErr != nil {
+			fail(dialErr)
+			continue
+		}
+
+		if err := s.write(conn, msg); err != nil {
+			fail(fmt.Errorf("writing syslog message for %s: %v", a.EventID, err))
+			// The stream may be broken, so the next message redials
+			conn.Close()
+			conn = nil
+		}
+	}
+	if conn != nil {
+		conn.Close()
+	}
+
+	if len(failures) > 0 {
+		return &DeliveryError{Kind: "syslog", Total: len(actions), Failures: failures}
+	}
+	return nil
+}
+
+// Resend sends a previously rendered message.
+func (s *Syslog) Resend(payload []byte) error {
+	conn, err := s.dial()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	return s.write(conn, string(payload))
+}
+
+// write sends a message, framing it for stream transports.
+func (s *Syslog) write(conn net.Conn, msg string) error {
+	if s.Network != "udp" {
+		msg = fmt.Sprintf("%d %s", len(msg), msg)
+	}
+	conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	_, err := conn.Write([]byte(msg))
+	return err
+}
+
+func (s *Syslog) dial() (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if s.Network == "tls" {
+		return tls.DialWithDialer(dialer, "tcp", s.Addr, s.TLSConfig)
+	}
+	return dialer.Dial(s.Network, s.Addr)
+}
+
+// Message renders an action as an RFC 5424 message.
+func (s *Syslog) Message(a *action.Action) (string, error) {
+	severity, ok := s.Severities[a.Severity]
+	if !ok {
+		severity = s.DefaultSeverity
+	}
+
+	timestamp := "-"
+	if !a.EventTime.IsZero() {
+		timestamp = a.EventTime.UTC().Format(time.RFC3339)
+	}
+
+	var sd strings.Builder
+	sd.WriteString("[" + s.SDID)
+	for _, p := range []struct{ name, value string }{
+		{"account", a.AccountID},
+		{"user", a.UserName},
+		{"eventSource", a.EventSource},
+		{"eventName", a.EventName},
+		{"eventID", a.EventID},
+		{"region", a.Region},
+		{"errorCode", a.ErrorCode},
+		{"severity", a.Severity},
+	} {
+		if p.value != "" {
+			fmt.Fprintf(&sd, ` %s="%s"`, p.name, escapeSDValue(p.value))
+		}
+	}
+	sd.WriteString("]")
+
+	msg := fmt.Sprintf("%s called %s on %s in %s", a.UserName, a.EventName, a.EventSource, a.AccountID)
+	if s.Format != "" {
+		var err error
+		if msg, err = marshalAction(a, s.Format); err != nil {
+			return "", err
+		}
+	}
+
+	return fmt.Sprintf("<%d>1 %s %s %s - %s %s %s",
+		s.Facility*8+severity,
+		timestamp,
+		headerField(a.AccountID),
+		headerField(s.AppName),
+		syslogMsgID,
+		sd.String(),
+		msg,
+	), nil
+}
+
+// escapeSDValue escapes the characters RFC 5424 reserves in PARAM-VALUE.
+func escapeSDValue(v string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(v)
+}
+
+// headerField substitutes the nil value for empty header fields.
+func headerField(v string) string {
+	if v == "" {
+		return "-"
+	}
+	return strings.ReplaceAll(v, " ", "_")
+}
@@ -0,0 +1,123 @@
+package notify
+
+import (
+	"bufio"
+	"crypto/tls"
+	"crypto/x509"
+	"io"
+	"net"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFramed reads octet counted messages from a stream listener.
+func readFramed(t *testing.T, ln net.Listener, n int) []string {
+	conn, err := ln.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	r := bufio.NewReader(conn)
+	var msgs []string
+	for i := 0; i < n; i++ {
+		length, err := r.ReadString(' ')
+		if err != nil {
+			t.Fatal(err)
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(length))
+		buf := make([]byte, size)
+		if _, err := io.ReadFull(r, buf); err != nil {
+			t.Fatal(err)
+		}
+		msgs = append(msgs, string(buf))
+	}
+	return msgs
+}
+
+func TestSyslogMessage(t *testing.T) {
+	n, err := New("siem", Destination{
+		Type:    "syslog",
+		URL:     "udp://127.0.0.1:514",
+		Options: map[string]string{"facility": "auth", "severity_map": "low=info"},
+	}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := testGroups(1)[0].First()
+	a.UserName = `al"ice]`
+	a.EventTime = time.Date(2021, 5, 14, 19, 3, 40, 0, time.UTC)
+
+	msg, err := n.(*Syslog).Message(a)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// auth (4) * 8 + info (6)
+	want := `<38>1 2021-05-14T19:03:40Z 123456789012 cloudtrail-console-actions - ConsoleAction [console@32473 account="123456789012" user="al\"ice\]" eventSource="ec2.amazonaws.com" eventName="CreateTags" eventID="event-0" severity="low"] al"ice] called CreateTags on ec2.amazonaws.com in 123456789012`
+	if msg != want {
+		t.Fatalf("got  %s\nwant %s", msg, want)
+	}
+}
+
+func TestSyslogUDP(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	n, err := New("siem", Destination{Type: "syslog", URL: "udp://" + pc.LocalAddr().String()}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := n.Notify(testGroups(2)); err != nil {
+		t.Fatal(err)
+	}
+
+	buf := make([]byte, 2048)
+	pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	size, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(buf[:size]), "<133>1 ") {
+		t.Fatalf("unexpected datagram %s", buf[:size])
+	}
+}
+
+func TestSyslogTLS(t *testing.T) {
+	srv := httptest.NewUnstartedServer(nil)
+	srv.StartTLS()
+	defer srv.Close()
+
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: srv.TLS.Certificates})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	n, err := New("siem", Destination{Type: "syslog", URL: "tls://" + ln.Addr().String(), Format: "ocsf"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pool := x509.NewCertPool()
+	pool.AddCert(srv.Certificate())
+	n.(*Syslog).TLSConfig = &tls.Config{RootCAs: pool, ServerName: "example.com"}
+
+	done := make(chan error, 1)
+	go func() { done <- n.Notify(testGroups(2)) }()
+
+	msgs := readFramed(t, ln, 2)
+	if err := <-done; err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(msgs[1], `eventID="event-1"`) || !strings.Contains(msgs[1], `"class_uid":6003`) {
+		t.Fatalf("unexpected message %s", msgs[1])
+	}
+}
@@ -0,0 +1,284 @@
+package ocsf
+
+import (
+	"strings"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+)
+
+const (
+	// Version is the OCSF schema version the events conform to.
+	Version = "1.1.0"
+
+	categoryUID = 6
+	classUID    = 6003
+)
+
+// Activity IDs defined by the API Activity class.
+const (
+	ActivityUnknown = 0
+	ActivityCreate  = 1
+	ActivityRead    = 2
+	ActivityUpdate  = 3
+	ActivityDelete  = 4
+	ActivityOther   = 99
+)
+
+// Status IDs shared by every OCSF class.
+const (
+	StatusUnknown = 0
+	StatusSuccess = 1
+	StatusFailure = 2
+)
+
+// APIActivity is an OCSF API Activity event (class 6003).
+type APIActivity struct {
+	ActivityID   int    `json:"activity_id"`
+	ActivityName string `json:"activity_name"`
+	CategoryUID  int    `json:"category_uid"`
+	CategoryName string `json:"category_name"`
+	ClassUID     int    `json:"class_uid"`
+	ClassName    string `json:"class_name"`
+	TypeUID      int    `json:"type_uid"`
+	SeverityID   int    `json:"severity_id"`
+	Severity     string `json:"severity,omitempty"`
+	StatusID     int    `json:"status_id"`
+	Status       string `json:"status"`
+	Time         int64  `json:"time"`
+
+	Metadata    Metadata     `json:"metadata"`
+	Actor       Actor        `json:"actor"`
+	API         API          `json:"api"`
+	SrcEndpoint Endpoint     `json:"src_endpoint"`
+	Cloud       Cloud        `json:"cloud"`
+	HTTPRequest *HTTPRequest `json:"http_request,omitempty"`
+	Unmapped    *Unmapped    `json:"unmapped,omitempty"`
+}
+
+// Metadata describes the event and the product that logged it.
+type Metadata struct {
+	UID     string   `json:"uid"`
+	Version string   `json:"version"`
+	Product Product  `json:"product"`
+	Labels  []string `json:"labels,omitempty"`
+}
+
+// Product is the logging product.
+type Product struct {
+	Name       string `json:"name"`
+	VendorName string `json:"vendor_name"`
+}
+
+// Actor is the principal that made the call.
+type Actor struct {
+	User      User     `json:"user"`
+	InvokedBy string   `json:"invoked_by,omitempty"`
+	Session   *Session `json:"session,omitempty"`
+}
+
+// User identifies the calling principal.
+type User struct {
+	Name    string   `json:"name,omitempty"`
+	UID     string   `json:"uid,omitempty"`
+	Type    string   `json:"type,omitempty"`
+	Account *Account `json:"account,omitempty"`
+}
+
+// Session carries the session context of temporary credentials.
+type Session struct {
+	CreatedTime int64  `json:"created_time,omitempty"`
+	IsMFA       bool   `json:"is_mfa"`
+	Issuer      string `json:"issuer,omitempty"`
+}
+
+// Account is a cloud account.
+type Account struct {
+	UID  string `json:"uid"`
+	Type string `json:"type"`
+}
+
+// API describes the called operation.
+type API struct {
+	Operation string    `json:"operation"`
+	Service   Service   `json:"service"`
+	Request   *Request  `json:"request,omitempty"`
+	Response  *Response `json:"response,omitempty"`
+}
+
+// Service is the AWS service that received the call.
+type Service struct {
+	Name string `json:"name"`
+}
+
+// Request identifies the API request.
+type Request struct {
+	UID string `json:"uid"`
+}
+
+// Response carries the error returned by a failed call.
+type Response struct {
+	Error        string `json:"error,omitempty"`
+	ErrorMessage string `json:"error_message,omitempty"`
+}
+
+// Endpoint is the source of the call.
+type Endpoint struct {
+	IP     string `json:"ip,omitempty"`
+	Domain string `json:"domain,omitempty"`
+}
+
+// Cloud is where the call was made.
+type Cloud struct {
+	Provider string   `json:"provider"`
+	Region   string   `json:"region,omitempty"`
+	Account  *Account `json:"account,omitempty"`
+}
+
+// HTTPRequest carries the caller's user agent.
+type HTTPRequest struct {
+	UserAgent string `json:"user_agent"`
+}
+
+// Unmapped holds fields without an OCSF attribute.
+type Unmapped struct {
+	S3URI string `json:"s3_uri,omitempty"`
+}
+
+// FromAction maps an action to an API Activity event.
+func FromAction(a *action.Action) *APIActivity {
+	activity := ActivityFor(a.EventName, a.Record)
+	userIdentity, _ := a.Record["userIdentity"].(map[string]interface{})
+
+	e := &APIActivity{
+		ActivityID:   activity,
+		ActivityName: activityNames[activity],
+		CategoryUID:  categoryUID,
+		CategoryName: "Application Activity",
+		ClassUID:     classUID,
+		ClassName:    "API Activity",
+		TypeUID:      classUID*100 + activity,
+		SeverityID:   SeverityID(a.Severity),
+		Severity:     a.Severity,
+		StatusID:     StatusSuccess,
+		Status:       "Success",
+		Metadata: Metadata{
+			UID:     a.EventID,
+			Version: Version,
+			Product: Product{Name: "CloudTrail", VendorName: "AWS"},
+			Labels:  a.Tags,
+		},
+		Actor: Actor{
+			User: User{
+				Name: a.UserName,
+				UID:  action.StringField(userIdentity, "arn"),
+				Type: action.StringField(userIdentity, "type"),
+			},
+			InvokedBy: action.StringField(userIdentity, "invokedBy"),
+		},
+		API: API{
+			Operation: a.EventName,
+			Service:   Service{Name: a.EventSource},
+		},
+		Cloud: Cloud{
+			Provider: "AWS",
+			Region:   a.Region,
+		},
+	}
+
+	if a.S3URI != "" {
+		e.Unmapped = &Unmapped{S3URI: a.S3URI}
+	}
+	if !a.EventTime.IsZero() {
+		e.Time = a.EventTime.UnixNano() / 1e6
+	}
+	if e.Actor.User.UID == "" {
+		e.Actor.User.UID = a.Principal
+	}
+	if a.IdentityAccountID != "" {
+		e.Actor.User.Account = &Account{UID: a.IdentityAccountID, Type: "AWS Account"}
+	}
+	if a.AccountID != "" {
+		e.Cloud.Account = &Account{UID: a.AccountID, Type: "AWS Account"}
+	}
+	if id := action.StringField(a.Record, "requestID"); id != "" {
+		e.API.Request = &Request{UID: id}
+	}
+	if a.ErrorCode != "" {
+		e.StatusID = StatusFailure
+		e.Status = "Failure"
+		e.API.Response = &Response{
+			Error:        a.ErrorCode,
+			ErrorMessage: action.StringField(a.Record, "errorMessage"),
+		}
+	}
+	if a.UserAgent != "" {
+		e.HTTPRequest = &HTTPRequest{UserAgent: a.UserAgent}
+	}
+
+	// AWS services call with their service principal instead of an address
+	if strings.HasSuffix(a.SourceIP, ".amazonaws.com") {
+		e.SrcEndpoint.Domain = a.SourceIP
+	} else {
+		e.SrcEndpoint.IP = a.SourceIP
+	}
+
+	if sessionContext, ok := userIdentity["sessionContext"].(map[string]interface{}); ok {
+		s := &Session{}
+		if attributes, ok := sessionContext["attributes"].(map[string]interface{}); ok {
+			s.IsMFA = action.StringField(attributes, "mfaAuthenticated") == "true"
+			if t, err := time.Parse(time.RFC3339, action.StringField(attributes, "creationDate")); err == nil {
+				s.CreatedTime = t.UnixNano() / 1e6
+			}
+		}
+		if issuer, ok := sessionContext["sessionIssuer"].(map[string]interface{}); ok {
+			s.Issuer = action.StringField(issuer, "arn")
+		}
+		e.Actor.Session = s
+	}
+
+	return e
+}
+
+var activityNames = map[int]string{
+	ActivityUnknown: "Unknown",
+	ActivityCreate:  "Create",
+	ActivityRead:    "Read",
+	ActivityUpdate:  "Update",
+	ActivityDelete:  "Delete",
+	ActivityOther:   "Other",
+}
+
+// ActivityFor derives the API Activity from the event name.
+func ActivityFor(eventName string, record map[string]interface{}) int {
+	if record["readOnly"] == true {
+		return ActivityRead
+	}
+
+	for _, p := range []struct {
+		prefixes []string
+		activity int
+	}{
+		{[]string{"Create", "Put", "Add", "Run", "Register", "Allocate", "Import", "Attach", "Associate", "Copy"}, ActivityCreate},
+		{[]string{"Get", "List", "Describe", "Head", "Lookup", "Search", "Select", "View"}, ActivityRead},
+		{[]string{"Update", "Modify", "Set", "Change", "Enable", "Disable", "Start", "Stop", "Reboot", "Reset", "Tag", "Untag", "Authorize", "Revoke"}, ActivityUpdate},
+		{[]string{"Delete", "Remove", "Terminate", "Deregister", "Release", "Detach", "Disassociate", "Purge"}, ActivityDelete},
+	} {
+		for _, prefix := range p.prefixes {
+			if strings.HasPrefix(eventName, prefix) {
+				return p.activity
+			}
+		}
+	}
+	return ActivityOther
+}
+
+// SeverityID maps a severity name to the OCSF severity_id. OCSF numbers
+// Informational through Critical 1 to 5, the same scale as severity.Rank.
+func SeverityID(sev string) int {
+	if strings.EqualFold(sev, "informational") {
+		sev = severity.Info
+	}
+	return severity.Rank(sev)
+}
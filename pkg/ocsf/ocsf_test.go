@@ -0,0 +1,95 @@
+package ocsf
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+)
+
+func TestFromAction(t *testing.T) {
+	record := map[string]interface{}{}
+	json.Unmarshal([]byte(`{
+		"eventName": "DeleteBucket",
+		"requestID": "req-1",
+		"errorMessage": "Access Denied",
+		"userIdentity": {
+			"type": "AssumedRole",
+			"arn": "arn:aws:sts::123456789012:assumed-role/Admin/alice",
+			"accountId": "123456789012",
+			"sessionContext": {
+				"attributes": {"mfaAuthenticated": "true", "creationDate": "2021-05-14T19:00:00Z"},
+				"sessionIssuer": {"arn": "arn:aws:iam::123456789012:role/Admin"}
+			}
+		}
+	}`), &record)
+
+	a := &action.Action{
+		EventID:           "event-1",
+		EventName:         "DeleteBucket",
+		EventSource:       "s3.amazonaws.com",
+		EventTime:         time.Date(2021, 5, 14, 19, 3, 40, 0, time.UTC),
+		AccountID:         "123456789012",
+		IdentityAccountID: "123456789012",
+		Region:            "us-east-1",
+		UserName:          "alice",
+		UserAgent:         "console.amazonaws.com",
+		ErrorCode:         "AccessDenied",
+		SourceIP:          "192.0.2.10",
+		Severity:          "high",
+		Record:            record,
+	}
+
+	e := FromAction(a)
+	if e.ClassUID != 6003 || e.ActivityID != ActivityDelete || e.TypeUID != 600304 {
+		t.Fatalf("unexpected classification %d/%d/%d", e.ClassUID, e.ActivityID, e.TypeUID)
+	}
+	if e.StatusID != StatusFailure || e.API.Response.Error != "AccessDenied" || e.API.Response.ErrorMessage != "Access Denied" {
+		t.Fatalf("unexpected status %+v", e.API.Response)
+	}
+	if e.Actor.User.UID != "arn:aws:sts::123456789012:assumed-role/Admin/alice" || !e.Actor.Session.IsMFA {
+		t.Fatalf("unexpected actor %+v", e.Actor)
+	}
+	if e.SrcEndpoint.IP != "192.0.2.10" || e.Cloud.Account.UID != "123456789012" || e.SeverityID != 4 {
+		t.Fatalf("unexpected endpoint, cloud or severity %+v %+v %d", e.SrcEndpoint, e.Cloud, e.SeverityID)
+	}
+	if e.Time != 1621019020000 || e.API.Request.UID != "req-1" || e.HTTPRequest.UserAgent != "console.amazonaws.com" {
+		t.Fatalf("unexpected time, request or user agent")
+	}
+}
+
+func TestActivityFor(t *testing.T) {
+	cases := map[string]int{
+		"CreateTags":      ActivityCreate,
+		"ModifyInstance":  ActivityUpdate,
+		"TerminateThings": ActivityDelete,
+		"ConsoleLogin":    ActivityOther,
+	}
+	for name, want := range cases {
+		if got := ActivityFor(name, nil); got != want {
+			t.Errorf("ActivityFor(%q) = %d, want %d", name, got, want)
+		}
+	}
+	if got := ActivityFor("AccessKubernetesApi", map[string]interface{}{"readOnly": true}); got != ActivityRead {
+		t.Errorf("expected readOnly records to be reads, got %d", got)
+	}
+}
+
+func TestSeverityID(t *testing.T) {
+	cases := map[string]int{
+		"info":          1,
+		"Informational": 1,
+		"low":           2,
+		"MEDIUM":        3,
+		"high":          4,
+		"critical":      5,
+		"":              0,
+		"unknown":       0,
+	}
+	for name, want := range cases {
+		if got := SeverityID(name); got != want {
+			t.Errorf("SeverityID(%q) = %d, want %d", name, got, want)
+		}
+	}
+}
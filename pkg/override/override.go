@@ -0,0 +1,167 @@
+package override
+
+import (
+	"fmt"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/route"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/suppress"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/watchlist"
+)
+
+// Scope selects accounts. Accounts and OUs hold glob patterns, see
+// matcher.Glob; an OU pattern also matches every OU nested below it.
+type Scope struct {
+	Accounts    []string `json:"accounts"`
+	AccountTags []string `json:"account_tags"`
+	OUs         []string `json:"ous"`
+}
+
+// Matches reports whether the account is in scope. An empty scope matches
+// nothing, so an override cannot silently apply everywhere.
+func (s *Scope) Matches(id string, accounts account.Directory) bool {
+	if len(s.Accounts) == 0 && len(s.AccountTags) == 0 && len(s.OUs) == 0 {
+		return false
+	}
+
+	if len(s.AccountTags) > 0 {
+		found := false
+		for _, tag := range s.AccountTags {
+			if accounts.HasTag(id, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return matcher.Glob(s.Accounts, id) && (len(s.OUs) == 0 || accounts.InOU(id, s.OUs))
+}
+
+// Override layers filter and notification settings on top of the global
+// configuration for the accounts in its scope.
+type Override struct {
+	Name  string `json:"name"`
+	Scope Scope  `json:"scope"`
+
+	// DisableFilters turns off the builtin filters whose signal matches,
+	// e.g. "userAgent" to keep calls from the CLI or "*" to keep
+	// everything. Signals are listed under Classification in the README.
+	DisableFilters []string `json:"disable_filters"`
+
+	// Suppress drops further records, optionally only during a window.
+	Suppress []suppress.Rule `json:"suppress"`
+
+	// Watchlist keeps further records whatever their user agent.
+	Watchlist []watchlist.Rule `json:"watchlist"`
+
+	// Routes are evaluated before the global routing rules.
+	Routes []route.Rule `json:"routes"`
+
+	// MinSeverity stops less severe actions from being notified. They are
+	// still logged and archived.
+	MinSeverity string `json:"min_severity"`
+}
+
+// Settings are the overrides that apply to one account, merged in order.
+type Settings struct {
+	// Names lists the overrides that applied.
+	Names []string
+
+	DisableFilters []string
+	Suppress       []suppress.Rule
+	Watchlist      watchlist.List
+	Routes         []route.Rule
+	MinSeverity    string
+}
+
+// For merges the overrides whose scope includes the account. Lists are
+// concatenated and later overrides replace MinSeverity. It returns nil when
+// no override applies.
+func For(overrides []Override, id string, accounts account.Directory) *Settings {
+	var s *Settings
+	for i := range overrides {
+		o := &overrides[i]
+		if !o.Scope.Matches(id, accounts) {
+			continue
+		}
+		if s == nil {
+			// Only the override rules are checked here, the builtin
+			// watchlist is applied globally
+			s = &Settings{Watchlist: watchlist.List{DisableBuiltin: true}}
+		}
+		s.Names = append(s.Names, o.Name)
+		s.DisableFilters = append(s.DisableFilters, o.DisableFilters...)
+		s.Suppress = append(s.Suppress, o.Suppress...)
+		s.Watchlist.Rules = append(s.Watchlist.Rules, o.Watchlist...)
+		s.Routes = append(s.Routes, o.Routes...)
+		if o.MinSeverity != "" {
+			s.MinSeverity = o.MinSeverity
+		}
+	}
+	return s
+}
+
+// FilterDisabled reports whether the builtin filter for a classification
+// signal is turned off.
+func (s *Settings) FilterDisabled(signal string) bool {
+	if s == nil || len(s.DisableFilters) == 0 {
+		return false
+	}
+	return matcher.Glob(s.DisableFilters, signal)
+}
+
+// Suppressed returns the override rule that drops the record.
+func (s *Settings) Suppressed(record map[string]interface{}, accountID, userName string) (*suppress.Rule, bool) {
+	if s == nil {
+		return nil, false
+	}
+	return suppress.Match(s.Suppress, record, accountID, userName)
+}
+
+// Watched returns the name of the override watchlist rule the record matches.
+func (s *Settings) Watched(record map[string]interface{}) (string, bool) {
+	if s == nil {
+		return "", false
+	}
+	return s.Watchlist.Match(record)
+}
+
+// Notifies reports whether the action is severe enough to be notified.
+func (s *Settings) Notifies(a *action.Action) bool {
+	if s == nil || s.MinSeverity == "" {
+		return true
+	}
+	return severity.Rank(a.Severity) >= severity.Rank(s.MinSeverity)
+}
+
+// Table returns the routing table for the account: the override routes
+// followed by the global rules, with the global default.
+func (s *Settings) Table(global *route.Table) *route.Table {
+	if s == nil || len(s.Routes) == 0 {
+		return global
+	}
+	rules := make([]route.Rule, 0, len(s.Routes)+len(global.Rules))
+	rules = append(rules, s.Routes...)
+	rules = append(rules, global.Rules...)
+	return &route.Table{Rules: rules, Default: global.Default}
+}
+
+// Validate checks the overrides' severities and suppression windows.
+func Validate(overrides []Override) error {
+	for i := range overrides {
+		o := &overrides[i]
+		if o.MinSeverity != "" && !severity.Valid(o.MinSeverity) {
+			return fmt.Errorf("override %d (%s): unknown min_severity %q", i, o.Name, o.MinSeverity)
+		}
+		if err := suppress.Validate(o.Suppress); err != nil {
+			return fmt.Errorf("override %d (%s): %v", i, o.Name, err)
+		}
+	}
+	return nil
+}
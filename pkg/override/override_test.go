@@ -0,0 +1,113 @@
+package override
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/route"
+)
+
+var accounts = account.Directory{
+	"111111111111": {Name: "sandbox-a", OU: "Root/Sandbox/Team"},
+	"222222222222": {Name: "security", Tags: []string{"security"}, OU: "Root/Security"},
+	"333333333333": {Name: "production", OU: "Root/Workloads/Prod"},
+}
+
+const testOverrides = `[
+	{
+		"name": "sandbox",
+		"scope": {"ous": ["Root/Sandbox"]},
+		"disable_filters": ["userAgent"],
+		"suppress": [{"name": "tags", "event_names": ["CreateTags"]}],
+		"min_severity": "medium"
+	},
+	{
+		"name": "security",
+		"scope": {"account_tags": ["security"]},
+		"disable_filters": ["*"],
+		"routes": [{"name": "all", "match": {}, "to": [{"destination": "security"}]}]
+	},
+	{
+		"name": "team",
+		"scope": {"accounts": ["1111*"]},
+		"watchlist": [{"name": "lambda", "event_sources": ["lambda.amazonaws.com"]}],
+		"min_severity": "high"
+	},
+	{
+		"name": "nothing",
+		"scope": {}
+	}
+]`
+
+func loadOverrides(t *testing.T) []Override {
+	var overrides []Override
+	if err := json.Unmarshal([]byte(testOverrides), &overrides); err != nil {
+		t.Fatal(err)
+	}
+	if err := Validate(overrides); err != nil {
+		t.Fatal(err)
+	}
+	return overrides
+}
+
+func TestFor(t *testing.T) {
+	overrides := loadOverrides(t)
+
+	sandbox := For(overrides, "111111111111", accounts)
+	if sandbox == nil || !reflect.DeepEqual(sandbox.Names, []string{"sandbox", "team"}) {
+		t.Fatalf("expected the nested OU and account overrides to apply, got %+v", sandbox)
+	}
+	if !sandbox.FilterDisabled("userAgent") || sandbox.FilterDisabled("readOnly") {
+		t.Error("expected only the user agent filter to be disabled")
+	}
+	if rule, ok := sandbox.Suppressed(map[string]interface{}{"eventName": "CreateTags"}, "111111111111", "alice"); !ok || rule.Label() != "rule tags" {
+		t.Errorf("expected CreateTags to be suppressed, got %+v", rule)
+	}
+	if rule, ok := sandbox.Watched(map[string]interface{}{"eventSource": "lambda.amazonaws.com", "eventName": "Invoke"}); !ok || rule != "lambda" {
+		t.Errorf("expected lambda calls to be watched, got %q", rule)
+	}
+	if sandbox.Notifies(&action.Action{Severity: "medium"}) || !sandbox.Notifies(&action.Action{Severity: "high"}) {
+		t.Error("expected the later min_severity to win")
+	}
+
+	security := For(overrides, "222222222222", accounts)
+	if !security.FilterDisabled("sessionCredentialFromConsole") {
+		t.Error("expected every filter to be disabled for the security account")
+	}
+
+	if ov := For(overrides, "333333333333", accounts); ov != nil {
+		t.Errorf("expected no overrides for production, got %+v", ov)
+	}
+
+	var none *Settings
+	if none.FilterDisabled("userAgent") || !none.Notifies(&action.Action{}) {
+		t.Error("expected nil settings to change nothing")
+	}
+}
+
+func TestTable(t *testing.T) {
+	overrides := loadOverrides(t)
+	global := &route.Table{
+		Rules:   []route.Rule{{Name: "iam", Match: route.Match{EventSources: []string{"iam.amazonaws.com"}}, To: []route.Target{{Destination: "iam"}}}},
+		Default: []route.Target{{Destination: "slack"}},
+	}
+
+	a := &action.Action{AccountID: "222222222222", EventSource: "iam.amazonaws.com"}
+	got := For(overrides, a.AccountID, accounts).Table(global).Route(a, accounts)
+	if !reflect.DeepEqual(got, []route.Target{{Destination: "security"}}) {
+		t.Errorf("expected the override route to come first, got %+v", got)
+	}
+
+	if table := For(overrides, "111111111111", accounts).Table(global); table != global {
+		t.Error("expected the global table when an override has no routes")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate([]Override{{Name: "bad", MinSeverity: "urgent"}}); err == nil {
+		t.Error("expected an unknown severity to be rejected")
+	}
+}
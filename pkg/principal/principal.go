@@ -0,0 +1,162 @@
+// Package principal matches the identity behind a CloudTrail record against
+// lists of principals that are always alerted on, such as break-glass roles,
+// or always suppressed, such as known automation.
+package principal
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+)
+
+// Tag marks actions kept because their principal is on the always alert list.
+const Tag = "principal"
+
+// Rule matches the principal that made a call. Fields hold glob patterns
+// matched with matcher.Glob.
+type Rule struct {
+	Name string `json:"name"`
+
+	// ARNs matches the userIdentity ARN, e.g.
+	// "arn:aws:sts::*:assumed-role/BreakGlass/*", and for role sessions
+	// also the role's own ARN, e.g. "arn:aws:iam::*:role/ops/BreakGlass",
+	// which must include the role's path. Roles matches a role whatever
+	// its path.
+	ARNs []string `json:"arns"`
+
+	// Roles matches the name of the role a session was issued for.
+	Roles []string `json:"roles"`
+
+	// Sessions matches the role session name, e.g. "GitHubActions*".
+	Sessions []string `json:"sessions"`
+
+	// Severity, on always alert rules, is the least severity matching
+	// actions are given.
+	Severity string `json:"severity"`
+}
+
+// Lists is the principals section of the configuration file. Always alert
+// rules win when a principal is on both lists.
+type Lists struct {
+	AlwaysAlert    []Rule `json:"always_alert"`
+	AlwaysSuppress []Rule `json:"always_suppress"`
+}
+
+// Validate checks that every rule matches something and the rules'
+// severities.
+func (l *Lists) Validate() error {
+	for _, r := range l.AlwaysAlert {
+		if r.empty() {
+			return fmt.Errorf("principal %s: a rule needs at least one of arns, roles or sessions", r.Name)
+		}
+		if r.Severity != "" && !severity.Valid(r.Severity) {
+			return fmt.Errorf("principal %s: unknown severity %q", r.Name, r.Severity)
+		}
+	}
+	for _, r := range l.AlwaysSuppress {
+		if r.empty() {
+			return fmt.Errorf("principal %s: a rule needs at least one of arns, roles or sessions", r.Name)
+		}
+		if r.Severity != "" {
+			return fmt.Errorf("principal %s: severity only applies to always_alert", r.Name)
+		}
+	}
+	return nil
+}
+
+// Identity is the principal behind a record, as rules match it.
+type Identity struct {
+	ARNs    []string
+	Role    string
+	Session string
+}
+
+// Of returns the principal behind a record. Role and Session are only set
+// for role sessions.
+func Of(record map[string]interface{}) Identity {
+	var id Identity
+	userIdentity, _ := record["userIdentity"].(map[string]interface{})
+	arn, _ := userIdentity["arn"].(string)
+	if arn != "" {
+		id.ARNs = append(id.ARNs, arn)
+	}
+
+	// arn:aws:sts::123456789012:assumed-role/RoleName/SessionName
+	if i := strings.Index(arn, ":assumed-role/"); i >= 0 {
+		parts := strings.Split(arn[i+len(":assumed-role/"):], "/")
+		id.Role = parts[0]
+		if len(parts) > 1 {
+			id.Session = parts[len(parts)-1]
+		}
+	}
+
+	// The session issuer names roles with a path correctly
+	sessionContext, _ := userIdentity["sessionContext"].(map[string]interface{})
+	issuer, _ := sessionContext["sessionIssuer"].(map[string]interface{})
+	if issuer["type"] == "Role" {
+		if roleARN, ok := issuer["arn"].(string); ok && roleARN != "" {
+			id.ARNs = append(id.ARNs, roleARN)
+		}
+		if name, ok := issuer["userName"].(string); ok && name != "" {
+			id.Role = name
+		}
+	}
+	return id
+}
+
+func (r *Rule) empty() bool {
+	return len(r.ARNs) == 0 && len(r.Roles) == 0 && len(r.Sessions) == 0
+}
+
+// Matches reports whether the rule matches the principal. A rule with no
+// fields matches nothing.
+func (r *Rule) Matches(id Identity) bool {
+	if r.empty() {
+		return false
+	}
+	if len(r.ARNs) > 0 && !matchAnyOf(r.ARNs, id.ARNs) {
+		return false
+	}
+	if len(r.Roles) > 0 && (id.Role == "" || !matcher.Glob(r.Roles, id.Role)) {
+		return false
+	}
+	if len(r.Sessions) > 0 && (id.Session == "" || !matcher.Glob(r.Sessions, id.Session)) {
+		return false
+	}
+	return true
+}
+
+// Alert returns the first always alert rule the record's principal matches.
+func (l *Lists) Alert(record map[string]interface{}) (*Rule, bool) {
+	return match(l.AlwaysAlert, record)
+}
+
+// Suppress returns the first always suppress rule the record's principal
+// matches.
+func (l *Lists) Suppress(record map[string]interface{}) (*Rule, bool) {
+	return match(l.AlwaysSuppress, record)
+}
+
+func match(rules []Rule, record map[string]interface{}) (*Rule, bool) {
+	if len(rules) == 0 {
+		return nil, false
+	}
+	id := Of(record)
+	for i := range rules {
+		if rules[i].Matches(id) {
+			return &rules[i], true
+		}
+	}
+	return nil, false
+}
+
+func matchAnyOf(patterns, values []string) bool {
+	for _, v := range values {
+		if matcher.Glob(patterns, v) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,111 @@
+package principal
+
+import "testing"
+
+func assumedRole(role, session string) map[string]interface{} {
+	return map[string]interface{}{
+		"userIdentity": map[string]interface{}{
+			"type": "AssumedRole",
+			"arn":  "arn:aws:sts::123456789012:assumed-role/" + role + "/" + session,
+			"sessionContext": map[string]interface{}{
+				"sessionIssuer": map[string]interface{}{
+					"type":     "Role",
+					"arn":      "arn:aws:iam::123456789012:role/ops/" + role,
+					"userName": role,
+				},
+			},
+		},
+	}
+}
+
+func TestOf(t *testing.T) {
+	id := Of(assumedRole("BreakGlass", "jane@example.com"))
+	if id.Role != "BreakGlass" || id.Session != "jane@example.com" {
+		t.Errorf("got role %q session %q", id.Role, id.Session)
+	}
+	if len(id.ARNs) != 2 || id.ARNs[1] != "arn:aws:iam::123456789012:role/ops/BreakGlass" {
+		t.Errorf("got arns %v", id.ARNs)
+	}
+
+	user := Of(map[string]interface{}{"userIdentity": map[string]interface{}{
+		"type": "IAMUser",
+		"arn":  "arn:aws:iam::123456789012:user/deploy",
+	}})
+	if user.Role != "" || user.Session != "" || len(user.ARNs) != 1 {
+		t.Errorf("got %+v", user)
+	}
+}
+
+func TestLists(t *testing.T) {
+	lists := &Lists{
+		AlwaysAlert: []Rule{
+			{Name: "break-glass", Roles: []string{"BreakGlass*"}},
+		},
+		AlwaysSuppress: []Rule{
+			{Name: "ci", Roles: []string{"Deploy"}, Sessions: []string{"GitHubActions*"}},
+			{Name: "terraform", ARNs: []string{"arn:aws:iam::*:role/ops/Terraform"}},
+			{Name: "break-glass-too", Roles: []string{"BreakGlass"}},
+			{Name: "bot", ARNs: []string{"arn:aws:iam::*:user/bot-*"}},
+		},
+	}
+
+	cases := []struct {
+		name            string
+		record          map[string]interface{}
+		alert, suppress string
+	}{
+		{"break-glass", assumedRole("BreakGlass", "jane"), "break-glass", "break-glass-too"},
+		{"ci session", assumedRole("Deploy", "GitHubActions-42"), "", "ci"},
+		{"ci other session", assumedRole("Deploy", "jane"), "", ""},
+		{"role arn", assumedRole("Terraform", "run"), "", "terraform"},
+		{"user arn", map[string]interface{}{"userIdentity": map[string]interface{}{"arn": "arn:aws:iam::123456789012:user/bot-backup"}}, "", "bot"},
+		{"no identity", map[string]interface{}{}, "", ""},
+	}
+	for _, c := range cases {
+		var alert, suppress string
+		if r, ok := lists.Alert(c.record); ok {
+			alert = r.Name
+		}
+		if r, ok := lists.Suppress(c.record); ok {
+			suppress = r.Name
+		}
+		if alert != c.alert || suppress != c.suppress {
+			t.Errorf("%s: got alert %q suppress %q, want %q %q", c.name, alert, suppress, c.alert, c.suppress)
+		}
+	}
+}
+
+func TestARNPath(t *testing.T) {
+	id := Of(assumedRole("BreakGlass", "jane"))
+	cases := map[string]bool{
+		"arn:aws:iam::*:role/BreakGlass":           false,
+		"arn:aws:iam::*:role/ops/BreakGlass":       true,
+		"arn:aws:iam::*:role/*/BreakGlass":         true,
+		"arn:aws:sts::*:assumed-role/BreakGlass/*": true,
+	}
+	for arn, want := range cases {
+		rule := Rule{Name: "break-glass", ARNs: []string{arn}}
+		if got := rule.Matches(id); got != want {
+			t.Errorf("%s: got %v, want %v", arn, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	roles := []string{"BreakGlass"}
+	if err := (&Lists{AlwaysAlert: []Rule{{Name: "a", Roles: roles, Severity: "high"}}}).Validate(); err != nil {
+		t.Error(err)
+	}
+	if err := (&Lists{AlwaysAlert: []Rule{{Name: "a", Roles: roles, Severity: "urgent"}}}).Validate(); err == nil {
+		t.Error("expected an unknown severity to be rejected")
+	}
+	if err := (&Lists{AlwaysSuppress: []Rule{{Name: "a", Roles: roles, Severity: "high"}}}).Validate(); err == nil {
+		t.Error("expected a severity on a suppress rule to be rejected")
+	}
+	if err := (&Lists{AlwaysAlert: []Rule{{Name: "empty", Severity: "high"}}}).Validate(); err == nil {
+		t.Error("expected an empty always alert rule to be rejected")
+	}
+	if err := (&Lists{AlwaysSuppress: []Rule{{Name: "empty"}}}).Validate(); err == nil {
+		t.Error("expected an empty always suppress rule to be rejected")
+	}
+}
@@ -0,0 +1,125 @@
+package route
+
+import (
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/severity"
+)
+
+// Target names a destination and optionally overrides its channel.
+type Target struct {
+	Destination string `json:"destination"`
+	Channel     string `json:"channel,omitempty"`
+}
+
+// Match selects actions. Fields hold glob patterns matched with matcher.Glob.
+type Match struct {
+	Accounts     []string `json:"accounts"`
+	AccountTags  []string `json:"account_tags"`
+	OUs          []string `json:"ous"`
+	EventSources []string `json:"event_sources"`
+	EventNames   []string `json:"event_names"`
+	ErrorCodes   []string `json:"error_codes"`
+	Regions      []string `json:"regions"`
+	Severities   []string `json:"severities"`
+	Users        []string `json:"users"`
+
+	// Clients matches the user agent's category, e.g. "terraform" or "cli".
+	Clients []string `json:"clients"`
+
+	// Tags matches actions carrying any of the tags, e.g. "watchlist".
+	Tags []string `json:"tags"`
+
+	// MinSeverity matches actions at least this severe.
+	MinSeverity string `json:"min_severity"`
+}
+
+// Rule sends matching actions to one or more targets. Evaluation stops at the
+// first matching rule unless Continue is set.
+type Rule struct {
+	Name     string   `json:"name"`
+	Match    Match    `json:"match"`
+	To       []Target `json:"to"`
+	Continue bool     `json:"continue"`
+}
+
+// Table is an ordered list of rules with a fallback route.
+type Table struct {
+	Rules   []Rule   `json:"rules"`
+	Default []Target `json:"default"`
+}
+
+// Route returns the targets an action should be delivered to.
+func (t *Table) Route(a *action.Action, accounts account.Directory) []Target {
+	var targets []Target
+	seen := make(map[Target]bool)
+	matched := false
+
+	for _, rule := range t.Rules {
+		if !rule.Match.Matches(a, accounts) {
+			continue
+		}
+		matched = true
+		for _, to := range rule.To {
+			if !seen[to] {
+				seen[to] = true
+				targets = append(targets, to)
+			}
+		}
+		if !rule.Continue {
+			break
+		}
+	}
+
+	if !matched {
+		return t.Default
+	}
+	return targets
+}
+
+// Matches reports whether the action satisfies every populated field.
+func (m *Match) Matches(a *action.Action, accounts account.Directory) bool {
+	if len(m.AccountTags) > 0 {
+		found := false
+		for _, tag := range m.AccountTags {
+			if accounts.HasTag(a.AccountID, tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if len(m.OUs) > 0 && !accounts.InOU(a.AccountID, m.OUs) {
+		return false
+	}
+
+	if len(m.Tags) > 0 {
+		found := false
+		for _, tag := range m.Tags {
+			if a.HasTag(tag) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+
+	if m.MinSeverity != "" && severity.Rank(a.Severity) < severity.Rank(m.MinSeverity) {
+		return false
+	}
+
+	return matcher.Glob(m.Accounts, a.AccountID) &&
+		matcher.Glob(m.EventSources, a.EventSource) &&
+		matcher.Glob(m.EventNames, a.EventName) &&
+		matcher.Glob(m.ErrorCodes, a.ErrorCode) &&
+		matcher.Glob(m.Regions, a.Region) &&
+		matcher.Glob(m.Severities, a.Severity) &&
+		matcher.Glob(m.Users, a.UserName) &&
+		matcher.Glob(m.Clients, a.Client)
+}
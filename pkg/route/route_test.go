@@ -0,0 +1,119 @@
+package route
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/account"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+)
+
+const testTable = `{
+	"rules": [
+		{
+			"name": "security",
+			"match": {"account_tags": ["security"]},
+			"to": [{"destination": "security"}],
+			"continue": true
+		},
+		{
+			"name": "sandbox",
+			"match": {"ous": ["Root/Sandbox"]},
+			"to": [{"destination": "sandbox"}]
+		},
+		{
+			"name": "severe",
+			"match": {"min_severity": "high"},
+			"to": [{"destination": "pager"}],
+			"continue": true
+		},
+		{
+			"name": "infrastructure-as-code",
+			"match": {"clients": ["terraform", "cloudformation"]},
+			"to": [{"destination": "slack", "channel": "#iac"}]
+		},
+		{
+			"name": "iam",
+			"match": {"event_sources": ["iam.amazonaws.com"], "event_names": ["Put*Policy", "Delete*"]},
+			"to": [{"destination": "slack", "channel": "#iam"}, {"destination": "pager"}]
+		},
+		{
+			"name": "errors",
+			"match": {"error_codes": ["?*"], "regions": ["us-*"]},
+			"to": [{"destination": "slack", "channel": "#errors"}]
+		}
+	],
+	"default": [{"destination": "slack"}]
+}`
+
+func TestRoute(t *testing.T) {
+	var table Table
+	if err := json.Unmarshal([]byte(testTable), &table); err != nil {
+		t.Fatal(err)
+	}
+
+	accounts := account.Directory{
+		"222222222222": {Name: "security", Tags: []string{"security"}},
+		"333333333333": {Name: "sandbox", OU: "Root/Sandbox/Team"},
+	}
+
+	cases := []struct {
+		name   string
+		action action.Action
+		want   []Target
+	}{
+		{
+			name:   "default",
+			action: action.Action{AccountID: "111111111111", EventSource: "ec2.amazonaws.com", EventName: "CreateTags"},
+			want:   []Target{{Destination: "slack"}},
+		},
+		{
+			name:   "event name pattern",
+			action: action.Action{AccountID: "111111111111", EventSource: "iam.amazonaws.com", EventName: "PutUserPolicy"},
+			want:   []Target{{Destination: "slack", Channel: "#iam"}, {Destination: "pager"}},
+		},
+		{
+			name:   "account tag continues",
+			action: action.Action{AccountID: "222222222222", EventSource: "iam.amazonaws.com", EventName: "DeleteRole"},
+			want:   []Target{{Destination: "security"}, {Destination: "slack", Channel: "#iam"}, {Destination: "pager"}},
+		},
+		{
+			name:   "error code in region",
+			action: action.Action{AccountID: "111111111111", EventName: "RunInstances", ErrorCode: "AccessDenied", Region: "us-west-2"},
+			want:   []Target{{Destination: "slack", Channel: "#errors"}},
+		},
+		{
+			name:   "error code outside region",
+			action: action.Action{AccountID: "111111111111", EventName: "RunInstances", ErrorCode: "AccessDenied", Region: "eu-west-1"},
+			want:   []Target{{Destination: "slack"}},
+		},
+		{
+			name:   "client category",
+			action: action.Action{AccountID: "111111111111", EventSource: "iam.amazonaws.com", EventName: "DeleteRole", Client: "terraform"},
+			want:   []Target{{Destination: "slack", Channel: "#iac"}},
+		},
+		{
+			name:   "nested ou",
+			action: action.Action{AccountID: "333333333333", EventSource: "iam.amazonaws.com", EventName: "DeleteRole"},
+			want:   []Target{{Destination: "sandbox"}},
+		},
+		{
+			name:   "minimum severity",
+			action: action.Action{AccountID: "111111111111", EventSource: "cloudtrail.amazonaws.com", EventName: "StopLogging", Severity: "critical"},
+			want:   []Target{{Destination: "pager"}},
+		},
+		{
+			name:   "below minimum severity",
+			action: action.Action{AccountID: "111111111111", EventSource: "ec2.amazonaws.com", EventName: "RunInstances", Severity: "medium"},
+			want:   []Target{{Destination: "slack"}},
+		},
+	}
+
+	for _, c := range cases {
+		got := table.Route(&c.action, accounts)
+		if !reflect.DeepEqual(got, c.want) {
+			t.Errorf("%s: got %+v, want %+v", c.name, got, c.want)
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package severity
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+)
+
+// Severities from least to most severe.
+const (
+	Info     = "info"
+	Low      = "low"
+	Medium   = "medium"
+	High     = "high"
+	Critical = "critical"
+)
+
+var ranks = map[string]int{Info: 1, Low: 2, Medium: 3, High: 4, Critical: 5}
+
+// Rank orders severities, returning 0 for an unknown or empty severity.
+func Rank(severity string) int {
+	return ranks[strings.ToLower(severity)]
+}
+
+// Valid reports whether severity is one of the known severities.
+func Valid(severity string) bool {
+	return Rank(severity) > 0
+}
+
+// Max returns the most severe of the actions' severities.
+func Max(actions []*action.Action) string {
+	var max string
+	for _, a := range actions {
+		if Rank(a.Severity) > Rank(max) {
+			max = a.Severity
+		}
+	}
+	return max
+}
+
+// Rule assigns a severity to matching actions. Fields hold glob patterns
+// matched with matcher.Glob. Resources are matched against the ARNs in the
+// record's resources list and the names in its request parameters.
+type Rule struct {
+	Name          string   `json:"name"`
+	EventSources  []string `json:"event_sources"`
+	EventNames    []string `json:"event_names"`
+	ErrorCodes    []string `json:"error_codes"`
+	IdentityTypes []string `json:"identity_types"`
+	Resources     []string `json:"resources"`
+
+	// Clients matches the user agent's category, e.g. "terraform" or "cli".
+	Clients []string `json:"clients"`
+
+	// MFA, when set, matches on whether the session used MFA.
+	MFA *bool `json:"mfa"`
+
+	Severity string `json:"severity"`
+}
+
+// Table scores actions with its rules. The most severe matching rule wins,
+// actions no rule matches get Default.
+type Table struct {
+	Default string `json:"default"`
+	Rules   []Rule `json:"rules"`
+
+	// DisableBuiltin drops the Builtin rules, which are otherwise evaluated
+	// alongside Rules.
+	DisableBuiltin bool `json:"disable_builtin"`
+}
+
+// DefaultSeverity is given to actions no rule matches when the table does not
+// set Default.
+const DefaultSeverity = Low
+
+var noMFA = false
+
+// Builtin covers changes that weaken logging, access control or encryption,
+// and sessions that should not be used in the console at all.
+var Builtin = []Rule{
+	{
+		Name:          "root",
+		IdentityTypes: []string{"Root"},
+		Severity:      Critical,
+	},
+	{
+		Name:         "audit-tampering",
+		EventSources: []string{"cloudtrail.amazonaws.com"},
+		EventNames:   []string{"StopLogging", "DeleteTrail", "UpdateTrail", "PutEventSelectors", "DeleteEventDataStore"},
+		Severity:     Critical,
+	},
+	{
+		Name:         "detection-tampering",
+		EventSources: []string{"guardduty.amazonaws.com", "securityhub.amazonaws.com", "config.amazonaws.com", "access-analyzer.amazonaws.com"},
+		EventNames:   []string{"Delete*", "Disable*", "Stop*", "DisassociateFromMasterAccount", "UpdateDetector"},
+		Severity:     High,
+	},
+	{
+		Name:          "iam-user-without-mfa",
+		IdentityTypes: []string{"IAMUser"},
+		MFA:           &noMFA,
+		Severity:      High,
+	},
+	{
+		Name:         "iam-privilege",
+		EventSources: []string{"iam.amazonaws.com"},
+		EventNames: []string{
+			"Attach*Policy", "Put*Policy", "CreatePolicyVersion", "SetDefaultPolicyVersion",
+			"CreateAccessKey", "CreateLoginProfile", "UpdateLoginProfile", "UpdateAssumeRolePolicy",
+			"AddUserToGroup", "CreateUser", "DeactivateMFADevice", "DeleteMFADevice",
+		},
+		Severity: High,
+	},
+	{
+		Name:         "key-destruction",
+		EventSources: []string{"kms.amazonaws.com"},
+		EventNames:   []string{"ScheduleKeyDeletion", "DisableKey", "PutKeyPolicy"},
+		Severity:     High,
+	},
+	{
+		Name:         "public-access",
+		EventSources: []string{"s3.amazonaws.com"},
+		EventNames:   []string{"PutBucketPolicy", "PutBucketAcl", "DeleteBucketPublicAccessBlock", "PutBucketPublicAccessBlock", "DeleteBucketEncryption"},
+		Severity:     High,
+	},
+	{
+		Name:         "network-exposure",
+		EventSources: []string{"ec2.amazonaws.com"},
+		EventNames:   []string{"AuthorizeSecurityGroupIngress", "CreateRoute", "AttachInternetGateway", "ModifySnapshotAttribute", "ModifyImageAttribute"},
+		Severity:     Medium,
+	},
+	{
+		Name: "destructive",
+		EventNames: []string{
+			"Terminate*", "DeleteBucket", "DeleteDB*", "DeleteTable", "DeleteStack", "DeleteCluster",
+			"DeleteFileSystem", "DeleteVolume", "DeleteSnapshot", "DeleteVpc", "DeleteSecret", "DeleteRepository",
+		},
+		Severity: Medium,
+	},
+	{
+		Name:       "access-denied",
+		ErrorCodes: []string{"AccessDenied*", "*UnauthorizedOperation", "UnauthorizedAccess"},
+		Severity:   Medium,
+	},
+	{
+		Name:       "tagging",
+		EventNames: []string{"CreateTags", "DeleteTags", "Tag*", "Untag*"},
+		Severity:   Info,
+	},
+}
+
+// Validate reports rules with unknown severities.
+func (t *Table) Validate() error {
+	if t.Default != "" && !Valid(t.Default) {
+		return fmt.Errorf("unknown default severity %q", t.Default)
+	}
+	for i, r := range t.Rules {
+		if !Valid(r.Severity) {
+			return fmt.Errorf("severity rule %d (%s): unknown severity %q", i, r.Name, r.Severity)
+		}
+	}
+	return nil
+}
+
+// Score returns the severity for an action and the name of the rule that set
+// it, which is empty when the default applied.
+func (t *Table) Score(a *action.Action) (string, string) {
+	rules := t.Rules
+	if !t.DisableBuiltin {
+		rules = append(append([]Rule{}, Builtin...), t.Rules...)
+	}
+
+	var severity, name string
+	for i := range rules {
+		r := &rules[i]
+		if Rank(r.Severity) > Rank(severity) && r.Matches(a) {
+			severity, name = strings.ToLower(r.Severity), r.Name
+		}
+	}
+
+	if severity == "" {
+		severity = DefaultSeverity
+		if t.Default != "" {
+			severity = strings.ToLower(t.Default)
+		}
+	}
+	return severity, name
+}
+
+// Matches reports whether the action satisfies every populated field.
+func (r *Rule) Matches(a *action.Action) bool {
+	if !matcher.Glob(r.EventSources, a.EventSource) ||
+		!matcher.Glob(r.EventNames, a.EventName) ||
+		!matcher.Glob(r.ErrorCodes, a.ErrorCode) ||
+		!matcher.Glob(r.IdentityTypes, IdentityType(a)) ||
+		!matcher.Glob(r.Clients, a.Client) {
+		return false
+	}
+
+	if r.MFA != nil && MFAUsed(a) != *r.MFA {
+		return false
+	}
+
+	if len(r.Resources) > 0 {
+		for _, res := range Resources(a) {
+			if matcher.Glob(r.Resources, res) {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// IdentityType returns the record's userIdentity.type, such as Root, IAMUser
+// or AssumedRole.
+func IdentityType(a *action.Action) string {
+	userIdentity, _ := a.Record["userIdentity"].(map[string]interface{})
+	t, _ := userIdentity["type"].(string)
+	return t
+}
+
+// MFAUsed reports whether the session behind the action authenticated with
+// MFA, from the session context or, for console sign-ins, the MFAUsed flag.
+func MFAUsed(a *action.Action) bool {
+	if extra, ok := a.Record["additionalEventData"].(map[string]interface{}); ok {
+		if used, ok := extra["MFAUsed"].(string); ok {
+			return used == "Yes"
+		}
+	}
+
+	userIdentity, _ := a.Record["userIdentity"].(map[string]interface{})
+	sessionContext, _ := userIdentity["sessionContext"].(map[string]interface{})
+	attributes, _ := sessionContext["attributes"].(map[string]interface{})
+	return attributes["mfaAuthenticated"] == "true"
+}
+
+// resourceParameters are request parameters that name the resource acted on.
+var resourceParameters = []string{
+	"bucketName", "roleName", "userName", "groupName", "policyArn", "keyId",
+	"name", "trailName", "functionName", "dBInstanceIdentifier", "tableName",
+	"secretId", "topicArn", "queueUrl", "clusterName", "repositoryName",
+}
+
+// Resources returns the ARNs and names of the resources an action touched.
+func Resources(a *action.Action) []string {
+	var out []string
+	if resources, ok := a.Record["resources"].([]interface{}); ok {
+		for _, r := range resources {
+			if m, ok := r.(map[string]interface{}); ok {
+				if arn, ok := m["ARN"].(string); ok && arn != "" {
+					out = append(out, arn)
+				}
+			}
+		}
+	}
+
+	if params, ok := a.Record["requestParameters"].(map[string]interface{}); ok {
+		for _, key := range resourceParameters {
+			if v, ok := params[key].(string); ok && v != "" {
+				out = append(out, v)
+			}
+		}
+	}
+	return out
+}
@@ -0,0 +1,128 @@
+package severity
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/action"
+)
+
+func testAction(record string) *action.Action {
+	var r map[string]interface{}
+	if err := json.Unmarshal([]byte(record), &r); err != nil {
+		panic(err)
+	}
+	return action.New(r, "alice", "123456789012", "")
+}
+
+const assumedRole = `"userIdentity": {"type": "AssumedRole", "sessionContext": {"attributes": {"mfaAuthenticated": "false"}}}`
+
+func TestBuiltin(t *testing.T) {
+	cases := []struct {
+		name   string
+		record string
+		want   string
+		rule   string
+	}{
+		{
+			name:   "tagging",
+			record: `{"eventSource": "ec2.amazonaws.com", "eventName": "CreateTags", ` + assumedRole + `}`,
+			want:   Info,
+			rule:   "tagging",
+		},
+		{
+			name:   "unmatched",
+			record: `{"eventSource": "ec2.amazonaws.com", "eventName": "RunInstances", ` + assumedRole + `}`,
+			want:   Low,
+		},
+		{
+			name:   "access denied",
+			record: `{"eventSource": "ec2.amazonaws.com", "eventName": "RunInstances", "errorCode": "Client.UnauthorizedOperation", ` + assumedRole + `}`,
+			want:   Medium,
+			rule:   "access-denied",
+		},
+		{
+			name:   "trail deleted",
+			record: `{"eventSource": "cloudtrail.amazonaws.com", "eventName": "DeleteTrail", ` + assumedRole + `}`,
+			want:   Critical,
+			rule:   "audit-tampering",
+		},
+		{
+			name:   "root",
+			record: `{"eventSource": "ec2.amazonaws.com", "eventName": "CreateTags", "userIdentity": {"type": "Root"}}`,
+			want:   Critical,
+			rule:   "root",
+		},
+		{
+			name:   "iam user without mfa",
+			record: `{"eventSource": "ec2.amazonaws.com", "eventName": "RunInstances", "userIdentity": {"type": "IAMUser", "sessionContext": {"attributes": {"mfaAuthenticated": "false"}}}}`,
+			want:   High,
+			rule:   "iam-user-without-mfa",
+		},
+		{
+			name:   "iam user with mfa",
+			record: `{"eventSource": "ec2.amazonaws.com", "eventName": "RunInstances", "userIdentity": {"type": "IAMUser", "sessionContext": {"attributes": {"mfaAuthenticated": "true"}}}}`,
+			want:   Low,
+		},
+	}
+
+	table := &Table{}
+	for _, c := range cases {
+		got, rule := table.Score(testAction(c.record))
+		if got != c.want || rule != c.rule {
+			t.Errorf("%s: got %s (%s), want %s (%s)", c.name, got, rule, c.want, c.rule)
+		}
+	}
+}
+
+func TestConfiguredTable(t *testing.T) {
+	var table Table
+	err := json.Unmarshal([]byte(`{
+		"default": "info",
+		"disable_builtin": true,
+		"rules": [
+			{"name": "prod-buckets", "resources": ["arn:aws:s3:::prod-*", "prod-*"], "severity": "high"},
+			{"name": "lambda", "event_sources": ["lambda.amazonaws.com"], "severity": "medium"}
+		]
+	}`), &table)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := table.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	prod := testAction(`{"eventSource": "s3.amazonaws.com", "eventName": "PutBucketTagging", "requestParameters": {"bucketName": "prod-data"}}`)
+	if got, rule := table.Score(prod); got != High || rule != "prod-buckets" {
+		t.Errorf("expected the sensitive bucket to score high, got %s (%s)", got, rule)
+	}
+
+	arn := testAction(`{"eventSource": "lambda.amazonaws.com", "eventName": "UpdateFunctionCode", "resources": [{"ARN": "arn:aws:s3:::prod-artifacts"}]}`)
+	if got, _ := table.Score(arn); got != High {
+		t.Errorf("expected the most severe rule to win, got %s", got)
+	}
+
+	trail := testAction(`{"eventSource": "cloudtrail.amazonaws.com", "eventName": "DeleteTrail"}`)
+	if got, _ := table.Score(trail); got != Info {
+		t.Errorf("expected builtin rules to be disabled, got %s", got)
+	}
+
+	table.Rules = append(table.Rules, Rule{Severity: "urgent"})
+	if err := table.Validate(); err == nil {
+		t.Error("expected an unknown severity to be rejected")
+	}
+}
+
+func TestMFAUsedConsoleLogin(t *testing.T) {
+	a := testAction(`{"eventName": "ConsoleLogin", "userIdentity": {"type": "IAMUser"}, "additionalEventData": {"MFAUsed": "No"}}`)
+	if MFAUsed(a) {
+		t.Error("expected MFAUsed=No to report no MFA")
+	}
+}
+
+func TestMax(t *testing.T) {
+	actions := []*action.Action{{Severity: Low}, {Severity: Critical}, {Severity: Medium}, {}}
+	if got := Max(actions); got != Critical {
+		t.Errorf("got %s, want critical", got)
+	}
+}
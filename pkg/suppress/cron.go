@@ -0,0 +1,166 @@
+package suppress
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cron is a parsed five field schedule: minute, hour, day of month, month
+// and day of week. Each field is a bitset of the values it allows.
+type cron struct {
+	minute, hour, dom, month, dow uint64
+
+	// Like cron(8), when both day fields are restricted a time matches
+	// if either does. Otherwise it must match both: a field starting
+	// with "*", such as "*/2", narrows the other instead.
+	domAny, dowAny bool
+}
+
+var cronFields = []struct {
+	name     string
+	min, max int
+}{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+// parseCron parses a schedule such as "0 22 * * 6" (Saturdays at 22:00).
+// Fields accept *, values, ranges (1-5), lists (1,3) and steps (*/15).
+// Sunday is 0 or 7.
+func parseCron(spec string) (*cron, error) {
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFields) {
+		return nil, fmt.Errorf("schedule %q: expected %d fields, got %d", spec, len(cronFields), len(fields))
+	}
+
+	var sets [5]uint64
+	for i, f := range fields {
+		set, err := parseCronField(f, cronFields[i].min, cronFields[i].max)
+		if err != nil {
+			return nil, fmt.Errorf("schedule %q: %s: %v", spec, cronFields[i].name, err)
+		}
+		sets[i] = set
+	}
+
+	c := &cron{
+		minute: sets[0],
+		hour:   sets[1],
+		dom:    sets[2],
+		month:  sets[3],
+		dow:    sets[4],
+		domAny: strings.HasPrefix(fields[2], "*"),
+		dowAny: strings.HasPrefix(fields[4], "*"),
+	}
+	// Fold Sunday as 7 onto 0
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	return c, nil
+}
+
+func parseCronField(field string, min, max int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		step := 1
+		if i := strings.Index(part, "/"); i >= 0 {
+			n, err := strconv.Atoi(part[i+1:])
+			if err != nil || n <= 0 {
+				return 0, fmt.Errorf("invalid step %q", part)
+			}
+			step = n
+			part = part[:i]
+		}
+
+		lo, hi := min, max
+		if part != "*" {
+			if i := strings.Index(part, "-"); i >= 0 {
+				var err error
+				if lo, err = strconv.Atoi(part[:i]); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+				if hi, err = strconv.Atoi(part[i+1:]); err != nil {
+					return 0, fmt.Errorf("invalid range %q", part)
+				}
+			} else {
+				n, err := strconv.Atoi(part)
+				if err != nil {
+					return 0, fmt.Errorf("invalid value %q", part)
+				}
+				lo, hi = n, n
+				if step > 1 {
+					// "5/15" means every 15 starting at 5
+					hi = max
+				}
+			}
+		}
+		if lo < min || hi > max || lo > hi {
+			return 0, fmt.Errorf("%q is outside %d-%d", part, min, max)
+		}
+
+		for v := lo; v <= hi; v += step {
+			set |= 1 << uint(v)
+		}
+	}
+	return set, nil
+}
+
+func (c *cron) matches(t time.Time) bool {
+	return c.day(t) &&
+		c.hour&(1<<uint(t.Hour())) != 0 &&
+		c.minute&(1<<uint(t.Minute())) != 0
+}
+
+// day reports whether the schedule fires at some time on t's date.
+func (c *cron) day(t time.Time) bool {
+	if c.month&(1<<uint(t.Month())) == 0 {
+		return false
+	}
+
+	dom := c.dom&(1<<uint(t.Day())) != 0
+	dow := c.dow&(1<<uint(t.Weekday())) != 0
+	if c.domAny || c.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
+
+// prev returns the last time at or before t the schedule fired, searching
+// back no further than the date of since. Times are read in t's location,
+// and wall clock times a DST change skips never fire.
+func (c *cron) prev(t, since time.Time) (time.Time, bool) {
+	loc := t.Location()
+	since = since.In(loc)
+	// Step over dates at noon, which every zone has
+	first := time.Date(since.Year(), since.Month(), since.Day(), 12, 0, 0, 0, loc)
+	day := time.Date(t.Year(), t.Month(), t.Day(), 12, 0, 0, 0, loc)
+	for today := true; !day.Before(first); day, today = day.AddDate(0, 0, -1), false {
+		if !c.day(day) {
+			continue
+		}
+		hour := 23
+		if today {
+			hour = t.Hour()
+		}
+		for h := hour; h >= 0; h-- {
+			if c.hour&(1<<uint(h)) == 0 {
+				continue
+			}
+			for m := 59; m >= 0; m-- {
+				if c.minute&(1<<uint(m)) == 0 {
+					continue
+				}
+				at := time.Date(day.Year(), day.Month(), day.Day(), h, m, 0, 0, loc)
+				if at.Hour() != h || at.Minute() != m || at.After(t) {
+					continue
+				}
+				return at, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
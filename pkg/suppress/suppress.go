@@ -0,0 +1,183 @@
+package suppress
+
+import (
+	"fmt"
+	"time"
+
+	// Lambda's provided runtimes do not ship a zoneinfo database
+	_ "time/tzdata"
+
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/useragent"
+)
+
+// Rule drops matching records. Fields hold glob patterns matched with
+// matcher.Glob.
+//
+// A rule can be limited to a window of time: between Start and End, and
+// when Schedule is set, for Duration after each time the schedule fires.
+// Records are compared by their eventTime, so late deliveries are judged by
+// when the call was made. Rules with a window must be validated before use.
+type Rule struct {
+	Name         string   `json:"name"`
+	Accounts     []string `json:"accounts"`
+	EventSources []string `json:"event_sources"`
+	EventNames   []string `json:"event_names"`
+	Users        []string `json:"users"`
+
+	// Principals matches the userIdentity ARN, e.g.
+	// "arn:aws:sts::*:assumed-role/Migration/*".
+	Principals []string `json:"principals"`
+
+	// Clients matches the user agent's category, e.g. "terraform" or "cli".
+	Clients []string `json:"clients"`
+
+	Start    *time.Time `json:"start"`
+	End      *time.Time `json:"end"`
+	Schedule string     `json:"schedule"`
+	Duration string     `json:"duration"`
+
+	// Timezone is the IANA zone Schedule is read in, UTC by default.
+	Timezone string `json:"timezone"`
+
+	cron     *cron
+	duration time.Duration
+	location *time.Location
+}
+
+// Validate parses the rule's window. A window must be scoped by at least
+// one other field, so that a typo cannot silence every alert.
+func (r *Rule) Validate() error {
+	if r.Windowed() && r.empty() {
+		return fmt.Errorf("suppression %s: a window needs at least one of accounts, event_sources, event_names, users, principals or clients", r.Name)
+	}
+	if r.Start != nil && r.End != nil && !r.End.After(*r.Start) {
+		return fmt.Errorf("suppression %s: end is not after start", r.Name)
+	}
+
+	if r.Schedule == "" {
+		if r.Duration != "" || r.Timezone != "" {
+			return fmt.Errorf("suppression %s: duration and timezone need a schedule", r.Name)
+		}
+		return nil
+	}
+
+	c, err := parseCron(r.Schedule)
+	if err != nil {
+		return fmt.Errorf("suppression %s: %v", r.Name, err)
+	}
+	d, err := time.ParseDuration(r.Duration)
+	if err != nil || d < time.Minute {
+		return fmt.Errorf("suppression %s: a schedule needs a duration of at least 1m, got %q", r.Name, r.Duration)
+	}
+	loc := time.UTC
+	if r.Timezone != "" {
+		if loc, err = time.LoadLocation(r.Timezone); err != nil {
+			return fmt.Errorf("suppression %s: %v", r.Name, err)
+		}
+	}
+
+	r.cron, r.duration, r.location = c, d, loc
+	return nil
+}
+
+// Validate validates every rule.
+func Validate(rules []Rule) error {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Windowed reports whether the rule only applies at certain times.
+func (r *Rule) Windowed() bool {
+	return r.Start != nil || r.End != nil || r.Schedule != ""
+}
+
+// Label names the rule for explain output, e.g. "window migration".
+func (r *Rule) Label() string {
+	if r.Windowed() {
+		return "window " + r.Name
+	}
+	return "rule " + r.Name
+}
+
+func (r *Rule) empty() bool {
+	return len(r.Accounts) == 0 && len(r.EventSources) == 0 && len(r.EventNames) == 0 &&
+		len(r.Users) == 0 && len(r.Principals) == 0 && len(r.Clients) == 0
+}
+
+// Active reports whether the rule's window includes t.
+func (r *Rule) Active(t time.Time) bool {
+	if r.Start != nil && t.Before(*r.Start) {
+		return false
+	}
+	if r.End != nil && !t.Before(*r.End) {
+		return false
+	}
+	if r.Schedule == "" {
+		return true
+	}
+	if r.cron == nil {
+		// Not validated
+		return false
+	}
+
+	// The window is open if the schedule last fired less than a duration ago
+	t = t.In(r.location).Truncate(time.Minute)
+	fired, ok := r.cron.prev(t, t.Add(-r.duration))
+	return ok && t.Sub(fired) < r.duration
+}
+
+// Matches reports whether a record in accountID made by userName matches
+// the rule. A rule with no scope matches nothing.
+func (r *Rule) Matches(record map[string]interface{}, accountID, userName string) bool {
+	if r.empty() {
+		return false
+	}
+
+	eventSource, _ := record["eventSource"].(string)
+	eventName, _ := record["eventName"].(string)
+	userIdentity, _ := record["userIdentity"].(map[string]interface{})
+	arn, _ := userIdentity["arn"].(string)
+	if !matcher.Glob(r.Accounts, accountID) ||
+		!matcher.Glob(r.EventSources, eventSource) ||
+		!matcher.Glob(r.EventNames, eventName) ||
+		!matcher.Glob(r.Users, userName) ||
+		!matcher.Glob(r.Principals, arn) {
+		return false
+	}
+
+	if len(r.Clients) > 0 {
+		userAgent, _ := record["userAgent"].(string)
+		if !matcher.Glob(r.Clients, useragent.Parse(userAgent).Category) {
+			return false
+		}
+	}
+
+	if r.Windowed() {
+		return r.Active(eventTime(record))
+	}
+	return true
+}
+
+// Match returns the first rule the record matches.
+func Match(rules []Rule, record map[string]interface{}, accountID, userName string) (*Rule, bool) {
+	for i := range rules {
+		if rules[i].Matches(record, accountID, userName) {
+			return &rules[i], true
+		}
+	}
+	return nil, false
+}
+
+func eventTime(record map[string]interface{}) time.Time {
+	if s, ok := record["eventTime"].(string); ok {
+		if t, err := time.Parse(time.RFC3339, s); err == nil {
+			return t
+		}
+	}
+	return time.Now()
+}
@@ -0,0 +1,198 @@
+package suppress
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+)
+
+func mustTime(s string) time.Time {
+	t, err := time.Parse(time.RFC3339, s)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func record(eventTime string) map[string]interface{} {
+	return map[string]interface{}{
+		"eventTime":    eventTime,
+		"eventSource":  "rds.amazonaws.com",
+		"eventName":    "ModifyDBInstance",
+		"userAgent":    "Mozilla/5.0 (Macintosh) Chrome/120.0.0.0 Safari/537.36",
+		"userIdentity": map[string]interface{}{"arn": "arn:aws:sts::123456789012:assumed-role/Migration/alice"},
+	}
+}
+
+func TestFixedWindow(t *testing.T) {
+	var rule Rule
+	err := json.Unmarshal([]byte(`{
+		"name": "migration",
+		"accounts": ["123456789012"],
+		"principals": ["arn:aws:sts::*:assumed-role/Migration/*"],
+		"start": "2024-03-01T20:00:00Z",
+		"end": "2024-03-02T04:00:00Z"
+	}`), &rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if rule.Label() != "window migration" {
+		t.Errorf("unexpected label %q", rule.Label())
+	}
+
+	cases := map[string]bool{
+		"2024-03-01T19:59:59Z": false,
+		"2024-03-01T20:00:00Z": true,
+		"2024-03-02T03:59:59Z": true,
+		"2024-03-02T04:00:00Z": false,
+	}
+	for at, want := range cases {
+		if got := rule.Matches(record(at), "123456789012", "alice"); got != want {
+			t.Errorf("%s: got %v, want %v", at, got, want)
+		}
+	}
+
+	if rule.Matches(record("2024-03-01T21:00:00Z"), "999999999999", "alice") {
+		t.Error("expected other accounts to be out of scope")
+	}
+	other := record("2024-03-01T21:00:00Z")
+	other["userIdentity"] = map[string]interface{}{"arn": "arn:aws:sts::123456789012:assumed-role/Admin/bob"}
+	if rule.Matches(other, "123456789012", "bob") {
+		t.Error("expected other principals to be out of scope")
+	}
+}
+
+func TestScheduledWindow(t *testing.T) {
+	// Saturdays 22:00 to Sunday 02:00 in New York
+	rule := Rule{
+		Name:         "weekly-patching",
+		EventSources: []string{"ec2.amazonaws.com", "rds.amazonaws.com"},
+		Schedule:     "0 22 * * 6",
+		Duration:     "4h",
+		Timezone:     "America/New_York",
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"2024-03-02T21:59:00-05:00": false,
+		"2024-03-02T22:00:00-05:00": true,
+		"2024-03-03T01:59:00-05:00": true,
+		"2024-03-03T02:00:00-05:00": false,
+		"2024-03-09T23:30:00-05:00": true,
+		"2024-03-05T22:30:00-05:00": false,
+		// Clocks go forward at 02:00 on March 10th
+		"2024-03-10T02:59:00-04:00": true,
+		"2024-03-10T03:00:00-04:00": false,
+	}
+	for at, want := range cases {
+		if got := rule.Matches(record(at), "123456789012", "alice"); got != want {
+			t.Errorf("%s: got %v, want %v", at, got, want)
+		}
+	}
+}
+
+func TestLongWindow(t *testing.T) {
+	// The first ten days of each quarter
+	rule := Rule{
+		Name:     "quarter-close",
+		Schedule: "0 0 1 1,4,7,10 *",
+		Duration: "240h",
+		Accounts: []string{"123456789012"},
+	}
+	if err := rule.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := map[string]bool{
+		"2024-03-31T23:59:00Z": false,
+		"2024-04-01T00:00:00Z": true,
+		"2024-04-10T23:59:00Z": true,
+		"2024-04-11T00:00:00Z": false,
+		"2024-06-15T12:00:00Z": false,
+	}
+	for at, want := range cases {
+		if got := rule.Active(mustTime(at)); got != want {
+			t.Errorf("%s: got %v, want %v", at, got, want)
+		}
+	}
+}
+
+func TestValidate(t *testing.T) {
+	start := mustTime("2024-03-01T00:00:00Z")
+	end := mustTime("2024-03-02T00:00:00Z")
+	scope := []string{"123456789012"}
+	bad := []Rule{
+		{Name: "backwards", Accounts: scope, Start: &end, End: &start},
+		{Name: "no-duration", Accounts: scope, Schedule: "0 22 * * 6"},
+		{Name: "bad-cron", Accounts: scope, Schedule: "0 25 * * *", Duration: "1h"},
+		{Name: "bad-zone", Accounts: scope, Schedule: "0 22 * * *", Duration: "1h", Timezone: "Mars/Olympus"},
+		{Name: "no-schedule", Accounts: scope, Duration: "1h"},
+		{Name: "no-scope", Start: &start, End: &end},
+		{Name: "no-scope-schedule", Schedule: "0 22 * * 6", Duration: "4h"},
+	}
+	for _, r := range bad {
+		if err := r.Validate(); err == nil {
+			t.Errorf("%s: expected an error", r.Name)
+		}
+	}
+
+	good := Rule{Name: "scoped", Accounts: scope, Start: &start, End: &end}
+	if err := good.Validate(); err != nil {
+		t.Error(err)
+	}
+}
+
+func TestMatch(t *testing.T) {
+	rules := []Rule{
+		{},
+		{Name: "terraform", Clients: []string{"terraform"}},
+		{Name: "rds", EventSources: []string{"rds.amazonaws.com"}},
+	}
+	rule, ok := Match(rules, record("2024-03-01T21:00:00Z"), "123456789012", "alice")
+	if !ok || rule.Label() != "rule rds" {
+		t.Errorf("expected the rds rule to match, got %+v", rule)
+	}
+}
+
+func TestCron(t *testing.T) {
+	cases := []struct {
+		spec string
+		at   string
+		want bool
+	}{
+		{"*/15 * * * *", "2024-03-01T10:30:00Z", true},
+		{"*/15 * * * *", "2024-03-01T10:31:00Z", false},
+		{"0 9-17 * * 1-5", "2024-03-01T12:00:00Z", true},
+		{"0 9-17 * * 1-5", "2024-03-02T12:00:00Z", false},
+		{"0 0 1 * *", "2024-03-01T00:00:00Z", true},
+		{"0 0 * * 7", "2024-03-03T00:00:00Z", true},
+		{"0 0 15 * 0", "2024-03-03T00:00:00Z", true},
+		{"30 2 1,15 1-6 *", "2024-03-15T02:30:00Z", true},
+		// "*/2" only narrows the day of month, so Mondays must also match it
+		{"*/2 * */2 * 1", "2024-03-04T10:00:00Z", false},
+		{"*/2 * */2 * 1", "2024-03-05T10:00:00Z", false},
+		{"*/2 * */2 * 1", "2024-03-11T10:00:00Z", true},
+		{"*/2 * * * 1", "2024-03-04T10:00:00Z", true},
+		{"*/2 * * * 1", "2024-03-05T10:00:00Z", false},
+	}
+	for _, c := range cases {
+		cr, err := parseCron(c.spec)
+		if err != nil {
+			t.Fatalf("%s: %v", c.spec, err)
+		}
+		if got := cr.matches(mustTime(c.at)); got != c.want {
+			t.Errorf("%s at %s: got %v, want %v", c.spec, c.at, got, c.want)
+		}
+	}
+
+	for _, spec := range []string{"* * * *", "60 * * * *", "*/0 * * * *", "5-1 * * * *", "a * * * *"} {
+		if _, err := parseCron(spec); err == nil {
+			t.Errorf("%s: expected an error", spec)
+		}
+	}
+}
@@ -0,0 +1,134 @@
+// Package useragent sorts the userAgent of a CloudTrail record into the kind
+// of client that made the call, such as the console, the AWS CLI or
+// Terraform, and extracts its version.
+package useragent
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Categories of client.
+const (
+	Console        = "console"
+	Browser        = "browser"
+	CloudShell     = "cloudshell"
+	CLI            = "cli"
+	SDK            = "sdk"
+	Terraform      = "terraform"
+	CloudFormation = "cloudformation"
+	CDK            = "cdk"
+	Pulumi         = "pulumi"
+	Service        = "service"
+	Unknown        = "unknown"
+)
+
+// Agent is a parsed user agent.
+type Agent struct {
+	Category string `json:"category"`
+	Name     string `json:"name,omitempty"`
+	Version  string `json:"version,omitempty"`
+}
+
+// String describes the client for people, e.g. "Terraform 1.7.5" or
+// "Chrome console".
+func (a Agent) String() string {
+	name := a.Name
+	if name == "" {
+		name = a.Category
+	}
+	if a.Category == Browser {
+		return name + " console"
+	}
+	if a.Version != "" {
+		return name + " " + a.Version
+	}
+	return name
+}
+
+var (
+	cliVersion     = regexp.MustCompile(`aws-cli/([0-9][0-9.]*)`)
+	terraformToken = regexp.MustCompile(`Terraform/([0-9][0-9.]*)`)
+	providerToken  = regexp.MustCompile(`terraform-provider-aws/([0-9][0-9.]*)`)
+	pulumiToken    = regexp.MustCompile(`(?i)pulumi[a-z-]*/v?([0-9][0-9.]*)`)
+	cdkToken       = regexp.MustCompile(`aws-cdk/([0-9][0-9.]*)`)
+	consoleHost    = regexp.MustCompile(`^(console|signin)(\.[a-z0-9-]+)*\.amazonaws\.com$`)
+	serviceHost    = regexp.MustCompile(`^[a-z0-9-]+(\.[a-z0-9-]+)*\.amazonaws\.com$`)
+	sdkToken       = regexp.MustCompile(`(?i)\b(aws-sdk-[a-z0-9-]+|boto3|botocore|aws-chalice)/v?([0-9][0-9.]*)`)
+
+	browserTokens = []struct {
+		Name  string
+		Token *regexp.Regexp
+	}{
+		// Order matters: Edge and Opera also claim to be Chrome, and
+		// Chrome claims to be Safari
+		{"Edge", regexp.MustCompile(`Edg(?:e|A|iOS)?/([0-9]+)`)},
+		{"Opera", regexp.MustCompile(`OPR/([0-9]+)`)},
+		{"Firefox", regexp.MustCompile(`Firefox/([0-9]+)`)},
+		{"Chrome", regexp.MustCompile(`Chrome/([0-9]+)`)},
+		{"Safari", regexp.MustCompile(`Version/([0-9]+)[0-9.]* .*Safari/`)},
+	}
+)
+
+// Parse classifies a CloudTrail userAgent.
+func Parse(ua string) Agent {
+	// Some services wrap the user agent they were called with in brackets
+	ua = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(ua, "["), "]"))
+
+	switch {
+	case ua == "":
+		return Agent{Category: Unknown}
+	case strings.Contains(ua, "exec-env/CloudShell"):
+		return Agent{Category: CloudShell, Name: "CloudShell", Version: find(cliVersion, ua)}
+	case terraformToken.MatchString(ua):
+		return Agent{Category: Terraform, Name: "Terraform", Version: find(terraformToken, ua)}
+	case providerToken.MatchString(ua):
+		// Older providers only name themselves
+		return Agent{Category: Terraform, Name: "Terraform AWS provider", Version: find(providerToken, ua)}
+	case pulumiToken.MatchString(ua):
+		return Agent{Category: Pulumi, Name: "Pulumi", Version: find(pulumiToken, ua)}
+	case cdkToken.MatchString(ua):
+		return Agent{Category: CDK, Name: "AWS CDK", Version: find(cdkToken, ua)}
+	case cliVersion.MatchString(ua):
+		return Agent{Category: CLI, Name: "AWS CLI", Version: find(cliVersion, ua)}
+	case consoleHost.MatchString(ua),
+		ua == "AWS CloudWatch Console",
+		strings.HasPrefix(ua, "AWS Signin"),
+		strings.HasPrefix(ua, "S3Console/"):
+		return Agent{Category: Console, Name: "AWS Console"}
+	case strings.HasPrefix(ua, "Mozilla/"):
+		return browser(ua)
+	case ua == "cloudformation.amazonaws.com":
+		return Agent{Category: CloudFormation, Name: "CloudFormation"}
+	case serviceHost.MatchString(ua):
+		return Agent{Category: Service, Name: ua}
+	case ua == "AWS Internal", strings.HasPrefix(ua, "Coral/"), strings.Contains(strings.ToLower(ua), "aws-internal"):
+		return Agent{Category: Service, Name: "AWS Internal"}
+	}
+
+	if m := sdkToken.FindStringSubmatch(ua); m != nil {
+		return Agent{Category: SDK, Name: m[1], Version: m[2]}
+	}
+
+	name := ua
+	if i := strings.IndexAny(name, " /"); i > 0 {
+		name = name[:i]
+	}
+	return Agent{Category: Unknown, Name: name}
+}
+
+func browser(ua string) Agent {
+	for _, b := range browserTokens {
+		if v := find(b.Token, ua); v != "" {
+			return Agent{Category: Browser, Name: b.Name, Version: v}
+		}
+	}
+	return Agent{Category: Browser, Name: "Browser"}
+}
+
+func find(re *regexp.Regexp, s string) string {
+	if m := re.FindStringSubmatch(s); len(m) > 1 {
+		return m[1]
+	}
+	return ""
+}
@@ -0,0 +1,43 @@
+package useragent
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	cases := []struct {
+		ua   string
+		want Agent
+		str  string
+	}{
+		{"console.amazonaws.com", Agent{Console, "AWS Console", ""}, "AWS Console"},
+		{"console.ec2.amazonaws.com", Agent{Console, "AWS Console", ""}, "AWS Console"},
+		{"[S3Console/0.4, aws-internal/3 aws-sdk-java/1.11.1030]", Agent{Console, "AWS Console", ""}, "AWS Console"},
+		{"Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36", Agent{Browser, "Chrome", "120"}, "Chrome console"},
+		{"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36 Edg/120.0.2210.61", Agent{Browser, "Edge", "120"}, "Edge console"},
+		{"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0", Agent{Browser, "Firefox", "121"}, "Firefox console"},
+		{"aws-cli/2.13.0 Python/3.11.4 Linux/4.14.255 exec-env/CloudShell exe/x86_64.amzn.2 prompt/off command/s3.ls", Agent{CloudShell, "CloudShell", "2.13.0"}, "CloudShell 2.13.0"},
+		{"aws-cli/1.18.69 Python/3.8.5 Darwin/19.6.0 botocore/1.17.53", Agent{CLI, "AWS CLI", "1.18.69"}, "AWS CLI 1.18.69"},
+		{"aws-cli/2.2.5 Python/3.8.8 Darwin/20.4.0 exe/x86_64 prompt/off command/cloudtrail.stop-logging", Agent{CLI, "AWS CLI", "2.2.5"}, "AWS CLI 2.2.5"},
+		{"APN/1.0 HashiCorp/1.0 Terraform/1.7.5 (+https://www.terraform.io) terraform-provider-aws/5.31.0 (+https://registry.terraform.io/providers/hashicorp/aws) aws-sdk-go-v2/1.24.0", Agent{Terraform, "Terraform", "1.7.5"}, "Terraform 1.7.5"},
+		{"aws-sdk-go/1.44.122 (go1.19.3; linux; amd64) APN/1.0 HashiCorp/1.0 Terraform/1.3.7 (+https://www.terraform.io)", Agent{Terraform, "Terraform", "1.3.7"}, "Terraform 1.3.7"},
+		{"aws-sdk-go/1.44.0 terraform-provider-aws/4.0.0", Agent{Terraform, "Terraform AWS provider", "4.0.0"}, "Terraform AWS provider 4.0.0"},
+		{"aws-sdk-go-v2/1.21.0 os/linux lang/go#1.21 md/GOOS#linux api/ec2#1.118.0 pulumi-aws/v6.9.0", Agent{Pulumi, "Pulumi", "6.9.0"}, "Pulumi 6.9.0"},
+		{"aws-sdk-js/2.1400.0 linux/v18.16.0 aws-cdk/2.100.0 promise", Agent{CDK, "AWS CDK", "2.100.0"}, "AWS CDK 2.100.0"},
+		{"cloudformation.amazonaws.com", Agent{CloudFormation, "CloudFormation", ""}, "CloudFormation"},
+		{"lambda.amazonaws.com", Agent{Service, "lambda.amazonaws.com", ""}, "lambda.amazonaws.com"},
+		{"AWS Internal", Agent{Service, "AWS Internal", ""}, "AWS Internal"},
+		{"Boto3/1.26.0 Python/3.10.8 Linux/5.10 Botocore/1.29.0", Agent{SDK, "Boto3", "1.26.0"}, "Boto3 1.26.0"},
+		{"aws-sdk-go/1.38.55 (go1.16; linux; amd64)", Agent{SDK, "aws-sdk-go", "1.38.55"}, "aws-sdk-go 1.38.55"},
+		{"curl/7.79.1", Agent{Unknown, "curl", ""}, "curl"},
+		{"", Agent{Unknown, "", ""}, "unknown"},
+	}
+
+	for _, c := range cases {
+		got := Parse(c.ua)
+		if got != c.want {
+			t.Errorf("%q: got %+v, want %+v", c.ua, got, c.want)
+		}
+		if got.String() != c.str {
+			t.Errorf("%q: got %q, want %q", c.ua, got.String(), c.str)
+		}
+	}
+}
@@ -0,0 +1,120 @@
+package watchlist
+
+import (
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/matcher"
+	"github.com/Techcadia/cloudtrail-console-actions/pkg/useragent"
+)
+
+// Tag marks actions kept because they are on the watchlist.
+const Tag = "watchlist"
+
+// Rule matches API calls that are always kept. Fields hold glob patterns
+// matched with matcher.Glob.
+type Rule struct {
+	Name         string   `json:"name"`
+	EventSources []string `json:"event_sources"`
+	EventNames   []string `json:"event_names"`
+
+	// Clients matches the user agent's category, e.g. "terraform" or "cli".
+	Clients []string `json:"clients"`
+}
+
+// List is the watchlist section of the configuration file. Calls matching
+// any rule are kept whatever their user agent, and even when the filters
+// would otherwise suppress them.
+type List struct {
+	Rules []Rule `json:"rules"`
+
+	// DisableBuiltin drops the Builtin rules, which are otherwise checked
+	// alongside Rules.
+	DisableBuiltin bool `json:"disable_builtin"`
+}
+
+// Builtin covers calls that disable auditing or detection, or expose data,
+// which matter just as much from the CLI or a leaked key as from the console.
+var Builtin = []Rule{
+	{
+		Name:         "cloudtrail",
+		EventSources: []string{"cloudtrail.amazonaws.com"},
+		EventNames:   []string{"StopLogging", "DeleteTrail", "UpdateTrail", "PutEventSelectors", "DeleteEventDataStore"},
+	},
+	{
+		Name:         "guardduty",
+		EventSources: []string{"guardduty.amazonaws.com"},
+		EventNames:   []string{"DeleteDetector", "UpdateDetector", "DisassociateFromMasterAccount", "DisassociateFromAdministratorAccount", "DeleteMembers", "StopMonitoringMembers", "CreateFilter", "DeletePublishingDestination"},
+	},
+	{
+		Name:         "securityhub",
+		EventSources: []string{"securityhub.amazonaws.com"},
+		EventNames:   []string{"DisableSecurityHub", "DisableImportFindingsForProduct", "BatchDisableStandards", "DeleteMembers"},
+	},
+	{
+		Name:         "config",
+		EventSources: []string{"config.amazonaws.com"},
+		EventNames:   []string{"StopConfigurationRecorder", "DeleteConfigurationRecorder", "DeleteDeliveryChannel"},
+	},
+	{
+		Name:         "s3-public-access",
+		EventSources: []string{"s3.amazonaws.com"},
+		EventNames:   []string{"PutBucketPublicAccessBlock", "DeleteBucketPublicAccessBlock", "PutAccountPublicAccessBlock", "DeleteAccountPublicAccessBlock"},
+	},
+	{
+		Name:         "s3-control-public-access",
+		EventSources: []string{"s3-control.amazonaws.com"},
+		EventNames:   []string{"PutPublicAccessBlock", "DeletePublicAccessBlock"},
+	},
+	{
+		Name:         "kms",
+		EventSources: []string{"kms.amazonaws.com"},
+		EventNames:   []string{"ScheduleKeyDeletion", "DisableKey"},
+	},
+	{
+		Name:         "logging",
+		EventSources: []string{"ec2.amazonaws.com", "logs.amazonaws.com"},
+		EventNames:   []string{"DeleteFlowLogs", "DeleteLogGroup", "DisableEbsEncryptionByDefault"},
+	},
+	{
+		Name:         "organizations",
+		EventSources: []string{"organizations.amazonaws.com"},
+		EventNames:   []string{"LeaveOrganization", "RemoveAccountFromOrganization", "DeleteOrganization"},
+	},
+}
+
+// Match returns the name of the first rule the record matches.
+func (l *List) Match(record map[string]interface{}) (string, bool) {
+	eventSource, _ := record["eventSource"].(string)
+	eventName, _ := record["eventName"].(string)
+	// Parsing the user agent is comparatively slow, so only do it for
+	// rules that ask for it
+	var client string
+	clientOf := func() string {
+		if client == "" {
+			userAgent, _ := record["userAgent"].(string)
+			client = useragent.Parse(userAgent).Category
+		}
+		return client
+	}
+
+	if !l.DisableBuiltin {
+		if name, ok := match(Builtin, eventSource, eventName, clientOf); ok {
+			return name, true
+		}
+	}
+	return match(l.Rules, eventSource, eventName, clientOf)
+}
+
+func match(rules []Rule, eventSource, eventName string, client func() string) (string, bool) {
+	for _, r := range rules {
+		// An empty rule would keep every call
+		if len(r.EventSources) == 0 && len(r.EventNames) == 0 && len(r.Clients) == 0 {
+			continue
+		}
+		if !matcher.Glob(r.EventSources, eventSource) || !matcher.Glob(r.EventNames, eventName) {
+			continue
+		}
+		if len(r.Clients) == 0 || matcher.Glob(r.Clients, client()) {
+			return r.Name, true
+		}
+	}
+	return "", false
+}
@@ -0,0 +1,32 @@
+package watchlist
+
+import "testing"
+
+func TestMatch(t *testing.T) {
+	list := &List{Rules: []Rule{
+		{Name: "backups", EventSources: []string{"backup.amazonaws.com"}, EventNames: []string{"Delete*"}},
+		{Name: "empty"},
+	}}
+
+	cases := []struct {
+		source, name string
+		want         string
+	}{
+		{"cloudtrail.amazonaws.com", "StopLogging", "cloudtrail"},
+		{"guardduty.amazonaws.com", "DeleteDetector", "guardduty"},
+		{"s3.amazonaws.com", "PutBucketPublicAccessBlock", "s3-public-access"},
+		{"backup.amazonaws.com", "DeleteBackupVault", "backups"},
+		{"ec2.amazonaws.com", "RunInstances", ""},
+	}
+	for _, c := range cases {
+		got, ok := list.Match(map[string]interface{}{"eventSource": c.source, "eventName": c.name})
+		if got != c.want || ok != (c.want != "") {
+			t.Errorf("%s %s: got %q %t, want %q", c.source, c.name, got, ok, c.want)
+		}
+	}
+
+	list.DisableBuiltin = true
+	if _, ok := list.Match(map[string]interface{}{"eventSource": "cloudtrail.amazonaws.com", "eventName": "StopLogging"}); ok {
+		t.Error("expected builtin rules to be disabled")
+	}
+}